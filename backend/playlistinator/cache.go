@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// Function to build the match cache with TTLs from MATCH_CACHE_TTL and MATCH_CACHE_NOT_FOUND_TTL
+func NewMatchCacheFromEnv(db *store.DB) *store.MatchCache {
+	cache := store.NewMatchCache(db)
+	cache.TTL = envDuration("MATCH_CACHE_TTL", store.DefaultMatchTTL)
+	cache.NotFoundTTL = envDuration("MATCH_CACHE_NOT_FOUND_TTL", store.DefaultNotFoundTTL)
+	return cache
+}
+
+const cacheUsage = `usage: playlistinator cache <command>
+
+commands:
+  list             show every cached search result and ISRC lookup
+  purge [-expired] delete cached results and ISRC lookups, or only the expired ones
+  export [-o file] write the cache as JSON to stdout or a file`
+
+// Function to inspect, purge or export the Spotify match cache
+func runCacheCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(cacheUsage)
+	}
+
+	db, err := OpenStoreFromEnv()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	cache := NewMatchCacheFromEnv(db)
+
+	switch args[0] {
+	case "list":
+		return listCache(cache, os.Stdout)
+
+	case "purge":
+		flags := flag.NewFlagSet("cache purge", flag.ContinueOnError)
+		expiredOnly := flags.Bool("expired", false, "Only delete entries older than their TTL")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		removed, err := cache.Purge(*expiredOnly)
+		if err != nil {
+			return err
+		}
+		removedIsrcs, err := cache.PurgeIsrcs(*expiredOnly)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d cached matches and %d ISRC lookups\n", removed, removedIsrcs)
+		return nil
+
+	case "export":
+		flags := flag.NewFlagSet("cache export", flag.ContinueOnError)
+		output := flags.String("o", "", "File to write to instead of stdout")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		var export CacheExport
+		if export.Matches, err = cache.All(); err != nil {
+			return err
+		}
+		if export.Isrcs, err = cache.AllIsrcs(); err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	}
+
+	return errors.New(cacheUsage)
+}
+
+// CacheExport is the JSON written by the cache export command
+type CacheExport struct {
+	Matches []store.Match      `json:"matches"`
+	Isrcs   []store.IsrcLookup `json:"isrcs"`
+}
+
+// Function to print the cache as a table, most recent first
+func listCache(cache *store.MatchCache, w io.Writer) error {
+	matches, err := cache.All()
+	if err != nil {
+		return err
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].CachedAt.After(matches[j].CachedAt)
+	})
+
+	now := time.Now()
+	found, notFound, expired := 0, 0, 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ARTIST\tTRACK\tRESULT\tCACHED\t")
+	for _, m := range matches {
+		result := m.Uri
+		if m.NotFound {
+			result = "not found"
+			notFound++
+		} else {
+			found++
+			if m.Score > 0 {
+				result = fmt.Sprintf("%s (%.2f)", m.Uri, m.Score)
+			}
+		}
+
+		age := now.Sub(m.CachedAt).Round(time.Hour).String()
+		if cache.Expired(m, now) {
+			age += " (expired)"
+			expired++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s ago\t\n", m.Artist, m.Track, result, age)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d cached matches: %d found, %d not found, %d expired\n", len(matches), found, notFound, expired)
+
+	lookups, err := cache.AllIsrcs()
+	if err != nil || len(lookups) == 0 {
+		return err
+	}
+	sort.Slice(lookups, func(i, j int) bool {
+		return lookups[i].CachedAt.After(lookups[j].CachedAt)
+	})
+
+	expired = 0
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nMBID\tISRCS\tCACHED\t")
+	for _, lookup := range lookups {
+		isrcs := strings.Join(lookup.Isrcs, ", ")
+		if isrcs == "" {
+			isrcs = "none"
+		}
+		age := now.Sub(lookup.CachedAt).Round(time.Hour).String()
+		if cache.IsrcsExpired(lookup, now) {
+			age += " (expired)"
+			expired++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s ago\t\n", lookup.Mbid, isrcs, age)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d cached ISRC lookups, %d expired\n", len(lookups), expired)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// Function to open the local database named by PLAYLISTINATOR_DB
+func OpenStoreFromEnv() (*store.DB, error) {
+	path := os.Getenv("PLAYLISTINATOR_DB")
+	if path == "" {
+		path = store.DefaultPath
+	}
+	return store.Open(path)
+}
+
+// Function to read a duration from the environment, falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring invalid %s=%q: %v\n", key, value, err)
+		return def
+	}
+	return d
+}
+
+// Function to read a number from the environment, falling back to def when unset or invalid
+func envFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring invalid %s=%q: %v\n", key, value, err)
+		return def
+	}
+	return f
+}
+
+// Function to run a subcommand such as "cache list". Returns an error for unknown commands.
+func RunCommand(args []string) error {
+	switch args[0] {
+	case "cache":
+		return runCacheCommand(args[1:])
+	case "generate":
+		return runGenerateCommand(args[1:])
+	case "override":
+		return runOverrideCommand(args[1:])
+	case "sync":
+		return runSyncCommand(args[1:])
+	case "top":
+		return runTopCommand(args[1:])
+	case "unmatched":
+		return runUnmatchedCommand(args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// Function to run the top command, which prints the ranking for a window without updating Spotify
+func runTopCommand(args []string) error {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	window := addWindowFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts := DefaultOptions()
+	if err := window.apply(&opts); err != nil {
+		return err
+	}
+	if os.Getenv("LASTFM_API_KEY") == "" || os.Getenv("LASTFM_USER") == "" {
+		return fmt.Errorf("%w: LASTFM_API_KEY and LASTFM_USER must be set in .env file", errNotConfigured)
+	}
+
+	ranked, err := GetLastFmSongs(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	// Print the track name, count and score of each track in rank order
+	fmt.Printf("Top %d songs from %s, ranked by %s\n", len(ranked), opts.WindowLabel(), opts.Ranking)
+	for _, trackCount := range ranked {
+		fmt.Printf("%s - %s - %d (%.2f)\n", trackCount.Track.Artist.Name, trackCount.Track.Name, trackCount.Count, trackCount.Score)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tejaskoundinya/playlistinator/normalize"
+)
+
+// DefaultConfigPath is where the generate command looks for playlist definitions
+const DefaultConfigPath = "playlists.yaml"
+
+// PlaylistConfig defines one playlist. Unset fields fall back to DefaultOptions.
+type PlaylistConfig struct {
+	Name string `yaml:"name"`
+	// Window is relative like "7d" or "1y". From and To are dates (YYYY-MM-DD) and override it.
+	Window      string `yaml:"window"`
+	From        string `yaml:"from"`
+	To          string `yaml:"to"`
+	Limit       int    `yaml:"limit"`
+	Public      bool   `yaml:"public"`
+	Description string `yaml:"description"`
+	Ranking     string `yaml:"ranking"`
+	// HalfLife is used by the decay ranking, e.g. "7d"
+	HalfLife string `yaml:"half_life"`
+
+	MaxPerArtist int `yaml:"max_per_artist"`
+	MaxPerAlbum  int `yaml:"max_per_album"`
+	MinArtists   int `yaml:"min_artists"`
+
+	MinPlays int          `yaml:"min_plays"`
+	Exclude  ExcludeRules `yaml:"exclude"`
+}
+
+// Config is the playlist definitions file
+type Config struct {
+	// Normalize applies to every playlist. Rules left out of the file stay enabled.
+	Normalize normalize.Rules `yaml:"normalize"`
+	// Exclude applies to every playlist, on top of the playlist's own rules
+	Exclude ExcludeRules `yaml:"exclude"`
+	// MatchThreshold is the lowest confidence accepted for a Spotify match, from 0 to 1
+	MatchThreshold float64 `yaml:"match_threshold"`
+
+	Playlists []PlaylistConfig `yaml:"playlists"`
+}
+
+// Options returns the pipeline options for the playlist
+func (c PlaylistConfig) Options() (Options, error) {
+	opts := DefaultOptions()
+	opts.PlaylistName = c.Name
+	opts.Public = c.Public
+	opts.Description = c.Description
+	opts.MaxPerArtist = c.MaxPerArtist
+	opts.MaxPerAlbum = c.MaxPerAlbum
+	opts.MinArtists = c.MinArtists
+	opts.MinPlays = c.MinPlays
+	opts.Exclude = c.Exclude
+	if err := applyRanking(&opts, c.Ranking, c.HalfLife); err != nil {
+		return Options{}, fmt.Errorf("playlist %q: %w", c.Name, err)
+	}
+	if err := applyWindow(&opts, c.From, c.To, c.Window, c.Limit); err != nil {
+		return Options{}, fmt.Errorf("playlist %q: %w", c.Name, err)
+	}
+	return opts, nil
+}
+
+// Function to read and check a playlist definitions file
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := Config{Normalize: normalize.DefaultRules()}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if len(config.Playlists) == 0 {
+		return nil, fmt.Errorf("%s defines no playlists", path)
+	}
+	names := make(map[string]bool)
+	for i, playlist := range config.Playlists {
+		if playlist.Name == "" {
+			return nil, fmt.Errorf("%s: playlist %d has no name", path, i+1)
+		}
+		if names[playlist.Name] {
+			return nil, fmt.Errorf("%s: playlist %q is defined twice", path, playlist.Name)
+		}
+		names[playlist.Name] = true
+	}
+	return &config, nil
+}
+
+// Options returns the pipeline options of every playlist in the file
+func (c *Config) Options() ([]Options, error) {
+	var playlists []Options
+	for _, playlist := range c.Playlists {
+		playlist.Exclude = c.Exclude.Merge(playlist.Exclude)
+		opts, err := playlist.Options()
+		if err != nil {
+			return nil, err
+		}
+		opts.Normalize = c.Normalize
+		playlists = append(playlists, opts)
+	}
+	return playlists, nil
+}
+
+// Function to run the generate command, which updates every playlist in the config file
+func runGenerateCommand(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	defaultPath := os.Getenv("PLAYLISTINATOR_CONFIG")
+	if defaultPath == "" {
+		defaultPath = DefaultConfigPath
+	}
+	configPath := flags.String("config", defaultPath, "Playlist definitions file")
+	offline := flags.Bool("offline", false, "Rank scrobbles from the local database instead of fetching them from Last.fm")
+	searchWorkers := flags.Int("workers", DefaultSearchWorkers, "Number of Spotify searches to run in parallel")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	playlists, err := config.Options()
+	if err != nil {
+		return err
+	}
+
+	db, err := OpenStoreFromEnv()
+	if err != nil {
+		log.Printf("Running without match cache: %v", err)
+		db = nil
+	} else {
+		defer db.Close()
+	}
+
+	pipeline, err := NewPipelineFromEnv(db)
+	if err != nil {
+		return err
+	}
+	pipeline.Progress = os.Stdout
+	pipeline.SearchWorkers = *searchWorkers
+	pipeline.LastFm.Logger = log.New(os.Stdout, "", 0)
+	if config.MatchThreshold > 0 {
+		pipeline.Resolver.Threshold = config.MatchThreshold
+	}
+
+	results, err := pipeline.RunAll(context.Background(), *offline, playlists)
+
+	fmt.Println()
+	for _, result := range results {
+		if result.PlaylistId != "" {
+			fmt.Printf("Added %d songs to playlist '%s'\n", len(result.Matched), result.PlaylistName)
+		}
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestConfig writes yaml to a temporary playlists.yaml and loads it
+func loadTestConfig(t *testing.T, yaml string) (*Config, error) {
+	path := filepath.Join(t.TempDir(), "playlists.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(path)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown playlist field", "playlists:\n  - name: A\n    windw: 7d\n", "windw"},
+		{"unknown top level field", "playlist:\n  - name: A\n", "playlist"},
+		{"unknown exclude field", "exclude:\n  artist: [Queen]\nplaylists:\n  - name: A\n", "artist"},
+		{"no playlists", "match_threshold: 0.7\n", "defines no playlists"},
+		{"playlist without a name", "playlists:\n  - window: 7d\n", "playlist 1 has no name"},
+		{"duplicate names", "playlists:\n  - name: A\n  - name: B\n  - name: A\n", `playlist "A" is defined twice`},
+		{"not yaml", "playlists: [", "reading"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.yaml)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigExcludes(t *testing.T) {
+	config, err := loadTestConfig(t, `
+exclude:
+  artists: [Rain Sounds]
+  patterns: ["(?i)podcast"]
+playlists:
+  - name: A
+    exclude:
+      artists: [Queen]
+      uris: [spotify:track:1]
+  - name: B
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playlists, err := config.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := playlists[0].Exclude, playlists[1].Exclude
+	if strings.Join(a.Artists, ",") != "Rain Sounds,Queen" || len(a.Patterns) != 1 || len(a.Uris) != 1 {
+		t.Errorf("A excludes %+v", a)
+	}
+	// The rules of one playlist don't leak into the next
+	if strings.Join(b.Artists, ",") != "Rain Sounds" || len(b.Patterns) != 1 || len(b.Uris) != 0 {
+		t.Errorf("B excludes %+v", b)
+	}
+	if len(config.Exclude.Artists) != 1 {
+		t.Errorf("global excludes changed to %+v", config.Exclude)
+	}
+}
+
+func TestConfigWindows(t *testing.T) {
+	config, err := loadTestConfig(t, `
+playlists:
+  - name: default
+  - name: window
+    window: 7d
+  - name: from overrides window
+    window: 7d
+    from: 2024-01-01
+    to: 2024-01-31
+  - name: to only
+    window: 2w
+    to: 2024-01-31
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playlists, err := config.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)
+	want := []struct{ from, to time.Time }{
+		{now.Add(-30 * day), now},
+		{now.Add(-7 * day), now},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), feb},
+		{feb.Add(-14 * day), feb},
+	}
+	for i, opts := range playlists {
+		from, to := opts.Range(now)
+		if !from.Equal(want[i].from) || !to.Equal(want[i].to) {
+			t.Errorf("%s: range %s to %s, want %s to %s", opts.PlaylistName, from, to, want[i].from, want[i].to)
+		}
+	}
+}
+
+func TestConfigOptionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		want     string
+	}{
+		{"bad window", "window: 7x", "invalid window"},
+		{"from after to", "from: 2024-02-01\n    to: 2024-01-01", "is not before"},
+		{"bad ranking", "ranking: loudest", "loudest"},
+		{"bad pattern", "exclude:\n      patterns: [\"(unclosed\"]", "invalid exclude pattern"},
+		{"unparsable description", "description: \"Top {{.Limit\"", "invalid description template"},
+		{"unknown description field", "description: \"Top {{.Plays}}\"", "invalid description template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadTestConfig(t, "playlists:\n  - name: A\n    "+tt.playlist+"\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = config.Options()
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), `playlist "A"`) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	config, err := LoadConfig("playlists.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Options(); err != nil {
+		t.Error(err)
+	}
+}
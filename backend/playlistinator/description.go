@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/ranking"
+)
+
+// DefaultDescription is the description of the original "TK - Hot 100" playlist
+const DefaultDescription = `Top {{.Limit}} songs from {{.Window}}. Top 10 most played:
+{{range .Top}}{{.Rank}}. {{.Artist}} - {{.Name}} ({{.Plays}} plays)
+{{end}}`
+
+// DescriptionTrack is one of the top tracks listed in a playlist description
+type DescriptionTrack struct {
+	Rank   int
+	Artist string
+	Name   string
+	Album  string
+	Plays  int
+	Score  float64
+}
+
+// DescriptionData is what description templates are rendered with
+type DescriptionData struct {
+	Name      string
+	Limit     int
+	Window    string
+	From      time.Time
+	To        time.Time
+	Scrobbles int
+	// Top holds the 10 highest ranked tracks
+	Top []DescriptionTrack
+}
+
+// Function to parse a description template, falling back to DefaultDescription when empty.
+// The template is rendered once with a sample track so misspelled fields fail here
+// rather than halfway through a run.
+func parseDescription(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultDescription
+	}
+	tmpl, err := template.New("description").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := DescriptionData{Top: []DescriptionTrack{{Rank: 1}}}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Function to render the playlist description for ranked tracks
+func renderDescription(opts Options, scrobbles int, trackCounts []ranking.Ranked) (string, error) {
+	tmpl, err := parseDescription(opts.Description)
+	if err != nil {
+		return "", err
+	}
+
+	from, to := opts.Range(time.Now())
+	data := DescriptionData{
+		Name:      opts.PlaylistName,
+		Limit:     opts.Limit,
+		Window:    opts.WindowLabel(),
+		From:      from,
+		To:        to,
+		Scrobbles: scrobbles,
+	}
+	for i, tc := range trackCounts {
+		if i < 10 {
+			data.Top = append(data.Top, DescriptionTrack{
+				Rank:   i + 1,
+				Artist: tc.Track.Artist.Name,
+				Name:   tc.Track.Name,
+				Album:  tc.Track.Album.Name,
+				Plays:  tc.Count,
+				Score:  tc.Score,
+			})
+		}
+	}
+
+	var description strings.Builder
+	if err := tmpl.Execute(&description, data); err != nil {
+		return "", err
+	}
+	return description.String(), nil
+}
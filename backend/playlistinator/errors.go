@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/spotify"
+)
+
+// Steps of the generate pipeline, reported with errors so callers know what failed
+const (
+	stepConfig   = "config"
+	stepFetch    = "fetch"
+	stepCount    = "count"
+	stepToken    = "token"
+	stepPlaylist = "playlist"
+	stepDescribe = "describe"
+	stepSearch   = "search"
+	stepAdd      = "add"
+)
+
+var (
+	// errNotConfigured is returned when required credentials are missing from the environment
+	errNotConfigured = errors.New("not configured")
+
+	// errMethodNotAllowed is returned for requests with the wrong HTTP method
+	errMethodNotAllowed = errors.New("method not allowed")
+
+	// errBadRequest is returned when an API request body cannot be decoded
+	errBadRequest = errors.New("bad request")
+
+	// errNotFound is returned when an API request names something that doesn't exist
+	errNotFound = errors.New("not found")
+
+	// errNoMatch is returned when a Spotify search succeeds but finds nothing
+	errNoMatch = errors.New("no matching track found")
+
+	// errSkipped is reported for tracks an override says to leave out
+	errSkipped = errors.New("skipped by override")
+
+	// errExcluded is reported for tracks that resolve to an excluded Spotify URI
+	errExcluded = errors.New("excluded Spotify URI")
+
+	// errDuplicate marks a track that resolved to the same Spotify track as a higher ranked one
+	errDuplicate = errors.New("same Spotify track as a higher ranked one")
+)
+
+// StepError wraps an error with the pipeline step it happened in
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorBody is the error object returned in API responses
+type ErrorBody struct {
+	Code       string `json:"code"`
+	Step       string `json:"step,omitempty"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+}
+
+// Function to map an error to an HTTP status code and a machine readable error code
+func errorStatus(err error) (int, string) {
+	var rateLimited spotify.ErrRateLimited
+
+	switch {
+	case errors.Is(err, errMethodNotAllowed):
+		return http.StatusMethodNotAllowed, "method_not_allowed"
+	case errors.Is(err, errBadRequest):
+		return http.StatusBadRequest, "bad_request"
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, errNotConfigured):
+		return http.StatusServiceUnavailable, "not_configured"
+	case errors.As(err, &rateLimited), errors.Is(err, lastfm.ErrRateLimitExceeded):
+		return http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, spotify.ErrUnauthorized):
+		return http.StatusBadGateway, "spotify_unauthorized"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "timeout"
+	case errors.Is(err, context.Canceled):
+		// The client went away, the status is only for the logs
+		return http.StatusServiceUnavailable, "canceled"
+	}
+
+	var stepErr *StepError
+	if errors.As(err, &stepErr) && stepErr.Step != stepConfig {
+		return http.StatusBadGateway, "upstream_error"
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// Function to write an error as a JSON GenerateResponse with a matching status code
+func writeError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	log.Printf("Request failed with %d: %v", status, err)
+
+	body := &ErrorBody{
+		Code:    code,
+		Message: err.Error(),
+	}
+
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		body.Step = stepErr.Step
+	}
+
+	var rateLimited spotify.ErrRateLimited
+	if errors.As(err, &rateLimited) && rateLimited.RetryAfter > 0 {
+		body.RetryAfter = int(rateLimited.RetryAfter.Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(body.RetryAfter))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(GenerateResponse{
+		Success: false,
+		Message: err.Error(),
+		Error:   body,
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/spotify"
+)
+
+func TestWriteError(t *testing.T) {
+	// The Spotify client hands back rate limits wrapped by the retry policy once it gives up
+	gaveUp := retry.Policy{MaxAttempts: 1}.Do(context.Background(), func(ctx context.Context) error {
+		return spotify.ErrRateLimited{RetryAfter: 30 * time.Second}
+	})
+
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantCode       string
+		wantStep       string
+		wantRetryAfter string
+	}{
+		{"method", fmt.Errorf("%w: PUT", errMethodNotAllowed), http.StatusMethodNotAllowed, "method_not_allowed", "", ""},
+		{"bad request", fmt.Errorf("%w: unexpected EOF", errBadRequest), http.StatusBadRequest, "bad_request", "", ""},
+		{"not found", fmt.Errorf("%w: no override", errNotFound), http.StatusNotFound, "not_found", "", ""},
+		{"not configured", fmt.Errorf("%w: LASTFM_API_KEY is missing", errNotConfigured), http.StatusServiceUnavailable, "not_configured", "", ""},
+		{"not configured in a step", &StepError{Step: stepToken, Err: errNotConfigured}, http.StatusServiceUnavailable, "not_configured", stepToken, ""},
+		{"spotify rate limit", &StepError{Step: stepSearch, Err: gaveUp}, http.StatusTooManyRequests, "rate_limited", stepSearch, "30"},
+		{"spotify rate limit without a wait", spotify.ErrRateLimited{}, http.StatusTooManyRequests, "rate_limited", "", ""},
+		{"last.fm rate limit", &StepError{Step: stepFetch, Err: fmt.Errorf("fetching Last.fm tracks: %w", lastfm.ErrRateLimitExceeded)}, http.StatusTooManyRequests, "rate_limited", stepFetch, ""},
+		{"spotify unauthorized", &StepError{Step: stepToken, Err: spotify.ErrUnauthorized}, http.StatusBadGateway, "spotify_unauthorized", stepToken, ""},
+		{"timeout", &StepError{Step: stepAdd, Err: context.DeadlineExceeded}, http.StatusGatewayTimeout, "timeout", stepAdd, ""},
+		{"canceled", context.Canceled, http.StatusServiceUnavailable, "canceled", "", ""},
+		{"upstream", &StepError{Step: stepPlaylist, Err: errors.New("spotify: 500")}, http.StatusBadGateway, "upstream_error", stepPlaylist, ""},
+		{"config step", &StepError{Step: stepConfig, Err: errors.New("bad window")}, http.StatusInternalServerError, "internal_error", stepConfig, ""},
+		{"anything else", errors.New("disk full"), http.StatusInternalServerError, "internal_error", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+
+			var body GenerateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Success || body.Message != tt.err.Error() || body.Error == nil {
+				t.Fatalf("body = %+v", body)
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Step != tt.wantStep || body.Error.Message != tt.err.Error() {
+				t.Errorf("error = %+v, want code %q and step %q", body.Error, tt.wantCode, tt.wantStep)
+			}
+			if tt.wantRetryAfter != "" && fmt.Sprint(body.Error.RetryAfter) != tt.wantRetryAfter {
+				t.Errorf("retryAfter = %d, want %s", body.Error.RetryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/tejaskoundinya/playlistinator/normalize"
+	"github.com/tejaskoundinya/playlistinator/ranking"
+)
+
+// ExcludeRules keep tracks out of a playlist. Artist, track and album names are
+// compared after normalization, patterns are regular expressions matched against
+// the artist, the title and the album as Last.fm sends them.
+type ExcludeRules struct {
+	Artists  []string `yaml:"artists" json:"artists"`
+	Tracks   []string `yaml:"tracks" json:"tracks"`
+	Albums   []string `yaml:"albums" json:"albums"`
+	Patterns []string `yaml:"patterns" json:"patterns"`
+	Uris     []string `yaml:"uris" json:"uris"`
+}
+
+// Merge returns the rules of both
+func (e ExcludeRules) Merge(other ExcludeRules) ExcludeRules {
+	return ExcludeRules{
+		Artists:  append(append([]string(nil), e.Artists...), other.Artists...),
+		Tracks:   append(append([]string(nil), e.Tracks...), other.Tracks...),
+		Albums:   append(append([]string(nil), e.Albums...), other.Albums...),
+		Patterns: append(append([]string(nil), e.Patterns...), other.Patterns...),
+		Uris:     append(append([]string(nil), e.Uris...), other.Uris...),
+	}
+}
+
+// trackFilter applies the minimum play count and exclude rules of a playlist
+type trackFilter struct {
+	minPlays   int
+	normalizer *normalize.Normalizer
+	artists    map[string]bool
+	tracks     map[string]bool
+	albums     map[string]bool
+	patterns   []*regexp.Regexp
+	uris       map[string]bool
+}
+
+// Function to build the filter for the options. Fails on invalid patterns.
+func newTrackFilter(opts Options) (*trackFilter, error) {
+	n := normalize.New(opts.Normalize)
+	f := &trackFilter{
+		minPlays:   opts.MinPlays,
+		normalizer: n,
+		artists:    make(map[string]bool),
+		tracks:     make(map[string]bool),
+		albums:     make(map[string]bool),
+		uris:       make(map[string]bool),
+	}
+	for _, artist := range opts.Exclude.Artists {
+		f.artists[n.Artist(artist)] = true
+	}
+	for _, track := range opts.Exclude.Tracks {
+		f.tracks[n.Title(track)] = true
+	}
+	for _, album := range opts.Exclude.Albums {
+		f.albums[n.Album(album)] = true
+	}
+	for _, pattern := range opts.Exclude.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	for _, uri := range opts.Exclude.Uris {
+		f.uris[uri] = true
+	}
+	return f, nil
+}
+
+// excluded reports whether a ranked track is left out
+func (f *trackFilter) excluded(r ranking.Ranked) bool {
+	track := r.Track
+	switch {
+	case r.Count < f.minPlays:
+		return true
+	case f.artists[f.normalizer.Artist(track.Artist.Name)]:
+		return true
+	case f.tracks[f.normalizer.Title(track.Name)]:
+		return true
+	case track.Album.Name != "" && f.albums[f.normalizer.Album(track.Album.Name)]:
+		return true
+	}
+	for _, re := range f.patterns {
+		if re.MatchString(track.Artist.Name) || re.MatchString(track.Name) || re.MatchString(track.Album.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracks returns the ranked tracks that pass the filter, in order, and how many were left out
+func (f *trackFilter) Tracks(ranked []ranking.Ranked) ([]ranking.Ranked, int) {
+	var kept []ranking.Ranked
+	for _, r := range ranked {
+		if !f.excluded(r) {
+			kept = append(kept, r)
+		}
+	}
+	return kept, len(ranked) - len(kept)
+}
+
+// ExcludesUri reports whether a resolved Spotify track is excluded
+func (f *trackFilter) ExcludesUri(uri string) bool {
+	return f.uris[uri]
+}
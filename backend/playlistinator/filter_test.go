@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/ranking"
+)
+
+func rankedTrack(artist string, name string, album string, count int) ranking.Ranked {
+	track := lastfm.Track{Artist: lastfm.Artist{Name: artist}, Name: name, Album: lastfm.Album{Name: album}}
+	return ranking.Ranked{TrackCount: lastfm.TrackCount{Track: track, Count: count}}
+}
+
+func TestTrackFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		minPlays int
+		exclude  ExcludeRules
+		track    ranking.Ranked
+		excluded bool
+	}{
+		{"no rules", 0, ExcludeRules{}, rankedTrack("Queen", "Bohemian Rhapsody", "A Night at the Opera", 1), false},
+		{"enough plays", 3, ExcludeRules{}, rankedTrack("Queen", "Bohemian Rhapsody", "", 3), false},
+		{"too few plays", 3, ExcludeRules{}, rankedTrack("Queen", "Bohemian Rhapsody", "", 2), true},
+
+		{"artist", 0, ExcludeRules{Artists: []string{"Queen"}}, rankedTrack("Queen", "Bohemian Rhapsody", "", 1), true},
+		{"artist, other case and accents", 0, ExcludeRules{Artists: []string{"Beyonce"}}, rankedTrack("BEYONCÉ", "Halo", "", 1), true},
+		{"artist, featuring credit", 0, ExcludeRules{Artists: []string{"Queen"}}, rankedTrack("Queen feat. David Bowie", "Under Pressure", "", 1), true},
+		{"artist, different one", 0, ExcludeRules{Artists: []string{"Queen"}}, rankedTrack("Queensrÿche", "Silent Lucidity", "", 1), false},
+
+		{"track", 0, ExcludeRules{Tracks: []string{"Bohemian Rhapsody"}}, rankedTrack("Queen", "Bohemian Rhapsody - Remastered 2011", "", 1), true},
+		{"track, punctuation", 0, ExcludeRules{Tracks: []string{"dont stop me now"}}, rankedTrack("Queen", "Don't Stop Me Now", "", 1), true},
+		{"track, different one", 0, ExcludeRules{Tracks: []string{"Bohemian Rhapsody"}}, rankedTrack("Queen", "Radio Ga Ga", "", 1), false},
+
+		{"album", 0, ExcludeRules{Albums: []string{"Christmas Hits"}}, rankedTrack("Wham!", "Last Christmas", "Christmas Hits (Deluxe Edition)", 1), true},
+		{"album, none on the track", 0, ExcludeRules{Albums: []string{""}}, rankedTrack("Wham!", "Last Christmas", "", 1), false},
+
+		{"pattern on the title", 0, ExcludeRules{Patterns: []string{`(?i)\bchristmas\b`}}, rankedTrack("Wham!", "Last Christmas", "", 1), true},
+		{"pattern on the artist", 0, ExcludeRules{Patterns: []string{`^White Noise`}}, rankedTrack("White Noise Sleep", "Rain", "", 1), true},
+		{"pattern on the album", 0, ExcludeRules{Patterns: []string{`Soundtrack`}}, rankedTrack("Hans Zimmer", "Time", "Inception (Original Soundtrack)", 1), true},
+		{"pattern, no match", 0, ExcludeRules{Patterns: []string{`^Christmas$`}}, rankedTrack("Wham!", "Last Christmas", "", 1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.MinPlays = tt.minPlays
+			opts.Exclude = tt.exclude
+			f, err := newTrackFilter(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept, excluded := f.Tracks([]ranking.Ranked{tt.track})
+			if got := excluded == 1; got != tt.excluded || len(kept)+excluded != 1 {
+				t.Errorf("excluded = %d, kept %d, want excluded %v", excluded, len(kept), tt.excluded)
+			}
+		})
+	}
+}
+
+func TestTrackFilterKeepsOrder(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Exclude = ExcludeRules{Artists: []string{"ABBA"}}
+	f, err := newTrackFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, excluded := f.Tracks([]ranking.Ranked{
+		rankedTrack("Queen", "Bohemian Rhapsody", "", 9),
+		rankedTrack("ABBA", "Waterloo", "", 8),
+		rankedTrack("Queen", "Radio Ga Ga", "", 7),
+	})
+	if excluded != 1 || len(kept) != 2 || kept[0].Track.Name != "Bohemian Rhapsody" || kept[1].Track.Name != "Radio Ga Ga" {
+		t.Errorf("kept %+v, excluded %d", kept, excluded)
+	}
+}
+
+func TestTrackFilterUris(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Exclude = ExcludeRules{Uris: []string{"spotify:track:1"}}
+	f, err := newTrackFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.ExcludesUri("spotify:track:1") {
+		t.Error("excluded URI not excluded")
+	}
+	if f.ExcludesUri("spotify:track:2") || f.ExcludesUri("") {
+		t.Error("other URI excluded")
+	}
+}
+
+func TestTrackFilterInvalidPattern(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Exclude = ExcludeRules{Patterns: []string{`(unclosed`}}
+	if _, err := newTrackFilter(opts); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
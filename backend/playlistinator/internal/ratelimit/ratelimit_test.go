@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Slack for scheduling delays in timing assertions
+const slack = 50 * time.Millisecond
+
+func TestWaitSpacesRequests(t *testing.T) {
+	l := New(5)
+	if l.interval != 200*time.Millisecond {
+		t.Fatalf("interval = %s, want 200ms", l.interval)
+	}
+
+	start := time.Now()
+	var times []time.Duration
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		times = append(times, time.Since(start))
+	}
+
+	// The first request goes out right away, the rest 200ms apart
+	if times[0] > slack {
+		t.Errorf("first request waited %s", times[0])
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i] - times[i-1]; gap < 200*time.Millisecond-slack {
+			t.Errorf("request %d came %s after the one before, want 200ms", i+1, gap)
+		}
+	}
+}
+
+func TestWaitIsShared(t *testing.T) {
+	l := New(5)
+	start := time.Now()
+
+	var mu sync.Mutex
+	var times []time.Duration
+	var wg sync.WaitGroup
+	for g := 0; g < 3; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2; i++ {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				times = append(times, time.Since(start))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Six requests from three goroutines still get one slot each, 200ms apart
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if len(times) != 6 {
+		t.Fatalf("%d requests", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i] - times[i-1]; gap < 200*time.Millisecond-slack {
+			t.Errorf("request %d came %s after the one before, want 200ms", i+1, gap)
+		}
+	}
+	if total := times[5]; total < time.Second {
+		t.Errorf("six requests took %s, want at least 1s", total)
+	}
+}
+
+func TestWaitReturnsWhenCanceled(t *testing.T) {
+	l := New(1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next slot is a second away, the caller gives up long before that
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("waited %s after the context was done", waited)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v with a canceled context", err)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,148 @@
+// Package retry repeats failed API requests with exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// Retryable is implemented by errors that know whether repeating the request can help
+type Retryable interface {
+	Retryable() bool
+}
+
+// Delayer is implemented by errors that carry a server mandated wait, such as Retry-After
+type Delayer interface {
+	RetryDelay() time.Duration
+}
+
+// Policy describes how often and how long to retry
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	// AttemptTimeout bounds a single attempt, MaxElapsed bounds all attempts and waits together.
+	// Zero means no limit.
+	AttemptTimeout time.Duration
+	MaxElapsed     time.Duration
+
+	// OnRetry is called before waiting to retry a failed attempt
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// Clock and wait used by Do, replaced in tests
+var (
+	now   = time.Now
+	sleep = func(ctx context.Context, d time.Duration) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+)
+
+// DefaultPolicy suits interactive runs against the Last.fm and Spotify APIs
+var DefaultPolicy = Policy{
+	MaxAttempts:    5,
+	BaseDelay:      500 * time.Millisecond,
+	MaxDelay:       30 * time.Second,
+	AttemptTimeout: 20 * time.Second,
+	MaxElapsed:     2 * time.Minute,
+}
+
+// Do calls fn until it succeeds, fails with an error that is not worth retrying,
+// or the policy runs out of attempts or time. The last error is returned.
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.MaxElapsed)
+		defer cancel()
+	}
+
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = p.attempt(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !shouldRetry(err) {
+			return err
+		}
+		if attempt >= maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		delay := p.backoff(attempt)
+		var delayer Delayer
+		if errors.As(err, &delayer) && delayer.RetryDelay() > delay {
+			delay = delayer.RetryDelay()
+		}
+
+		// Don't sleep past the overall deadline only to fail afterwards
+		if deadline, ok := ctx.Deadline(); ok && now().Add(delay).After(deadline) {
+			return err
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+
+		if sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return fn(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// backoff returns the exponential delay before the next attempt with jitter in [d/2, d]
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func shouldRetry(err error) bool {
+	var retryable Retryable
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+
+	// A single attempt timing out is worth another try, the caller's deadline was checked before
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,204 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for now and sleep. Sleeping records the wait and moves the clock on.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{now: time.Now()}
+	realNow, realSleep := now, sleep
+	now = func() time.Time { return clock.now }
+	sleep = func(ctx context.Context, d time.Duration) error {
+		clock.sleeps = append(clock.sleeps, d)
+		clock.now = clock.now.Add(d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { now, sleep = realNow, realSleep })
+	return clock
+}
+
+// testError is retryable or not, and asks for a wait when delay is set
+type testError struct {
+	retryable bool
+	delay     time.Duration
+}
+
+func (e testError) Error() string             { return "test error" }
+func (e testError) Retryable() bool           { return e.retryable }
+func (e testError) RetryDelay() time.Duration { return e.delay }
+
+// failing returns a function failing with err the first n calls, and a pointer to the call count
+func failing(n int, err error) (func(ctx context.Context) error, *int) {
+	calls := 0
+	return func(ctx context.Context) error {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		want    time.Duration
+	}{
+		{"first", Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, 1, 100 * time.Millisecond},
+		{"doubles", Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, 3, 400 * time.Millisecond},
+		{"capped", Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, 5, time.Second},
+		{"capped after overflow", Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, 70, time.Second},
+		{"no cap", Policy{BaseDelay: 100 * time.Millisecond}, 6, 3200 * time.Millisecond},
+		{"no delay", Policy{}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Jitter keeps every delay in [want/2, want]
+			for i := 0; i < 1000; i++ {
+				got := tt.policy.backoff(tt.attempt)
+				if got < tt.want/2 || got > tt.want {
+					t.Fatalf("backoff = %s, want between %s and %s", got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	clock := useFakeClock(t)
+	var retries []int
+	policy := Policy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    3 * time.Second,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			retries = append(retries, attempt)
+		},
+	}
+
+	fn, calls := failing(3, testError{retryable: true})
+	if err := policy.Do(context.Background(), fn); err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 4 || len(retries) != 3 || len(clock.sleeps) != 3 {
+		t.Fatalf("calls = %d, retries = %v, sleeps = %v", *calls, retries, clock.sleeps)
+	}
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		if d := clock.sleeps[i]; d < max/2 || d > max {
+			t.Errorf("sleep %d = %s, want between %s and %s", i+1, d, max/2, max)
+		}
+	}
+}
+
+func TestDoGivesUp(t *testing.T) {
+	clock := useFakeClock(t)
+	failure := testError{retryable: true}
+	fn, calls := failing(10, failure)
+
+	err := Policy{MaxAttempts: 3, BaseDelay: time.Second}.Do(context.Background(), fn)
+	if !errors.Is(err, failure) || !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Errorf("err = %v", err)
+	}
+	if *calls != 3 || len(clock.sleeps) != 2 {
+		t.Errorf("calls = %d, sleeps = %v", *calls, clock.sleeps)
+	}
+}
+
+func TestDoRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		min, max time.Duration
+	}{
+		{"longer than the backoff", 10 * time.Second, 10 * time.Second, 10 * time.Second},
+		{"shorter than the backoff", time.Millisecond, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := useFakeClock(t)
+			fn, _ := failing(1, testError{retryable: true, delay: tt.delay})
+			if err := (Policy{MaxAttempts: 2, BaseDelay: time.Second}).Do(context.Background(), fn); err != nil {
+				t.Fatal(err)
+			}
+			if len(clock.sleeps) != 1 || clock.sleeps[0] < tt.min || clock.sleeps[0] > tt.max {
+				t.Errorf("sleeps = %v, want one between %s and %s", clock.sleeps, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDoStopsBeforeDeadline(t *testing.T) {
+	clock := useFakeClock(t)
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(time.Hour))
+	defer cancel()
+
+	// The server asks for a wait that ends after the caller's deadline
+	failure := testError{retryable: true, delay: 2 * time.Hour}
+	fn, calls := failing(10, failure)
+	err := Policy{MaxAttempts: 5, BaseDelay: time.Second}.Do(ctx, fn)
+	if err != failure {
+		t.Errorf("err = %v, want the failure itself", err)
+	}
+	if *calls != 1 || len(clock.sleeps) != 0 {
+		t.Errorf("calls = %d, sleeps = %v", *calls, clock.sleeps)
+	}
+}
+
+func TestDoStopsWhenCanceledWhileWaiting(t *testing.T) {
+	clock := useFakeClock(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		// The caller goes away right before the wait
+		OnRetry: func(attempt int, delay time.Duration, err error) { cancel() },
+	}
+
+	failure := testError{retryable: true}
+	fn, calls := failing(10, failure)
+	if err := policy.Do(ctx, fn); err != failure {
+		t.Errorf("err = %v, want the failure itself", err)
+	}
+	if *calls != 1 || len(clock.sleeps) != 1 {
+		t.Errorf("calls = %d, sleeps = %v", *calls, clock.sleeps)
+	}
+}
+
+func TestDoNotRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"not retryable", testError{retryable: false}, 1},
+		{"plain error", errors.New("bad request"), 1},
+		{"canceled", context.Canceled, 1},
+		{"attempt timed out", context.DeadlineExceeded, 3},
+		{"retryable", testError{retryable: true}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeClock(t)
+			fn, calls := failing(10, tt.err)
+			err := Policy{MaxAttempts: 3, BaseDelay: time.Second}.Do(context.Background(), fn)
+			if !errors.Is(err, tt.err) || *calls != tt.wantCalls {
+				t.Errorf("err = %v after %d calls, want %d calls", err, *calls, tt.wantCalls)
+			}
+		})
+	}
+}
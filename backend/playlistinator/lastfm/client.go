@@ -0,0 +1,274 @@
+// Package lastfm is a small client for the parts of the Last.fm API used to
+// build playlists from a user's listening history.
+package lastfm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/internal/ratelimit"
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+)
+
+const (
+	DefaultBaseURL = "https://ws.audioscrobbler.com/2.0/"
+
+	// Maximum page size allowed by user.getrecenttracks
+	MaxPerPage = 1000
+
+	// Last.fm asks clients to stay under 5 requests per second
+	MaxRequestsPerSecond = 5
+
+	// Pages fetched in parallel by default, enough to keep the rate limiter busy
+	DefaultConcurrency = 4
+)
+
+// Last.fm rate limits by API key and address, so all clients in a process share one limiter by default
+var defaultLimiter = ratelimit.New(MaxRequestsPerSecond)
+
+// Client talks to the Last.fm API. The zero value is not usable, use NewClient.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	ApiKey     string
+
+	// Retry controls how transient errors such as 8 (operation failed) and 29 (rate limit exceeded) are retried
+	Retry retry.Policy
+
+	// Limiter spaces out requests. A nil limiter does not limit.
+	Limiter *ratelimit.Limiter
+
+	// Extended requests extended=1 responses, which add artist URLs and images and the loved flag
+	Extended bool
+
+	// Concurrency is the number of pages fetched in parallel once the page count is known
+	Concurrency int
+
+	// Logger receives progress messages. Nothing is logged when nil.
+	Logger *log.Logger
+}
+
+// NewClient returns a client for the public Last.fm API
+func NewClient(apiKey string) *Client {
+	return &Client{
+		BaseURL:     DefaultBaseURL,
+		HTTPClient:  http.DefaultClient,
+		ApiKey:      apiKey,
+		Retry:       retry.DefaultPolicy,
+		Limiter:     defaultLimiter,
+		Concurrency: DefaultConcurrency,
+	}
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
+
+// GetRecentTracksPage fetches a single page of user.getrecenttracks scrobbled between
+// fromTimestamp and toTimestamp. A zero fromTimestamp means from the first scrobble,
+// a zero toTimestamp means up to now.
+func (c *Client) GetRecentTracksPage(ctx context.Context, user string, fromTimestamp int64, toTimestamp int64, page int) (*RecentTracksResponse, error) {
+	params := url.Values{}
+	params.Set("method", "user.getrecenttracks")
+	params.Set("user", user)
+	params.Set("api_key", c.ApiKey)
+	params.Set("format", "json")
+	params.Set("page", strconv.Itoa(page))
+	if fromTimestamp > 0 {
+		params.Set("from", strconv.FormatInt(fromTimestamp, 10))
+	}
+	if toTimestamp > 0 {
+		params.Set("to", strconv.FormatInt(toTimestamp, 10))
+	}
+	params.Set("limit", strconv.Itoa(MaxPerPage))
+	if c.Extended {
+		params.Set("extended", "1")
+	}
+
+	var recentTracksResponse RecentTracksResponse
+	err := c.Retry.Do(ctx, func(ctx context.Context) error {
+		return c.get(ctx, params, &recentTracksResponse)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lastfm: fetching recent tracks page %d: %w", page, err)
+	}
+
+	return &recentTracksResponse, nil
+}
+
+// get makes a single rate limited API call and decodes the response into out
+func (c *Client) get(ctx context.Context, params url.Values, out interface{}) error {
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Errors come back as {"error": 29, "message": "..."}, sometimes with a 200 status
+	var apiError struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiError) == nil && apiError.Error != 0 {
+		return &Error{Code: apiError.Error, Message: apiError.Message, StatusCode: resp.StatusCode}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &Error{StatusCode: resp.StatusCode}
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// GetRecentTracks fetches every track the user scrobbled between fromTimestamp and
+// toTimestamp, newest first. A toTimestamp of 0 means now. The first page reports how
+// many pages there are, the rest are fetched by a pool of c.Concurrency workers and
+// merged back in page order.
+func (c *Client) GetRecentTracks(ctx context.Context, user string, fromTimestamp int64, toTimestamp int64) ([]Track, error) {
+	// Pin the end of the range so scrobbles arriving mid-fetch don't shift tracks between pages
+	if toTimestamp <= 0 {
+		toTimestamp = time.Now().Unix()
+	}
+
+	c.logf("Fetching Last.fm page 1...")
+	first, err := c.GetRecentTracksPage(ctx, user, fromTimestamp, toTimestamp, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := 1
+	if total, err := strconv.Atoi(first.RecentTracks.Attr.TotalPages); err == nil && total > 1 {
+		totalPages = total
+	}
+
+	pages := make([][]Track, totalPages)
+	pages[0] = first.RecentTracks.Track
+	c.logf("Found %d tracks on page 1 of %d", len(pages[0]), totalPages)
+
+	if totalPages > 1 {
+		if err := c.fetchPages(ctx, user, fromTimestamp, toTimestamp, pages); err != nil {
+			return nil, err
+		}
+	}
+
+	var allTracks []Track
+	for _, tracks := range pages {
+		allTracks = append(allTracks, tracks...)
+	}
+	return allTracks, nil
+}
+
+// WalkRecentTracks is GetRecentTracks for histories too large to fetch in one go. It
+// calls fn with each page, oldest page first, fetching one page at a time. When a
+// fetch or fn fails, the pages passed to fn so far are every scrobble from
+// fromTimestamp up to some point, so a later call can resume from there.
+func (c *Client) WalkRecentTracks(ctx context.Context, user string, fromTimestamp int64, toTimestamp int64, fn func(tracks []Track) error) error {
+	// Page boundaries only stay put while the end of the range does
+	if toTimestamp <= 0 {
+		toTimestamp = time.Now().Unix()
+	}
+
+	c.logf("Fetching Last.fm page 1...")
+	first, err := c.GetRecentTracksPage(ctx, user, fromTimestamp, toTimestamp, 1)
+	if err != nil {
+		return err
+	}
+
+	totalPages := 1
+	if total, err := strconv.Atoi(first.RecentTracks.Attr.TotalPages); err == nil && total > 1 {
+		totalPages = total
+	}
+
+	// Pages are numbered newest first, so the first page is the last one passed on
+	for page := totalPages; page > 1; page-- {
+		c.logf("Fetching Last.fm page %d of %d...", page, totalPages)
+		resp, err := c.GetRecentTracksPage(ctx, user, fromTimestamp, toTimestamp, page)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp.RecentTracks.Track); err != nil {
+			return err
+		}
+	}
+	return fn(first.RecentTracks.Track)
+}
+
+// fetchPages fills pages[1:] using a bounded worker pool, stopping at the first error
+func (c *Client) fetchPages(ctx context.Context, user string, fromTimestamp int64, toTimestamp int64, pages [][]Track) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := c.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pages)-1 {
+		workers = len(pages) - 1
+	}
+
+	pageNumbers := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range pageNumbers {
+				resp, err := c.GetRecentTracksPage(ctx, user, fromTimestamp, toTimestamp, page)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				pages[page-1] = resp.RecentTracks.Track
+				c.logf("Found %d tracks on page %d of %d", len(pages[page-1]), page, len(pages))
+			}
+		}()
+	}
+
+feed:
+	for page := 2; page <= len(pages); page++ {
+		select {
+		case pageNumbers <- page:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(pageNumbers)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,211 @@
+package lastfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+)
+
+// newTestClient returns a client for a local stand-in of the Last.fm API
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewClient("key")
+	c.BaseURL = server.URL + "/"
+	c.Limiter = nil
+	c.Retry = retry.Policy{MaxAttempts: 3}
+	return c
+}
+
+// pageJSON renders a user.getrecenttracks page holding one track named after the page
+func pageJSON(page int, totalPages int) string {
+	return fmt.Sprintf(`{"recenttracks":{"track":[{"artist":{"#text":"Artist"},"album":{"#text":"Album"},"name":"Page %d","date":{"uts":"1728900001"}}],"@attr":{"user":"tk","page":"%d","totalPages":"%d"}}}`,
+		page, page, totalPages)
+}
+
+func trackNames(tracks []Track) []string {
+	var names []string
+	for _, track := range tracks {
+		names = append(names, track.Name)
+	}
+	return names
+}
+
+func TestGetRecentTracksPaginates(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		for key, want := range map[string]string{"method": "user.getrecenttracks", "user": "tk", "api_key": "key", "from": "100", "to": "200", "limit": "1000"} {
+			if got := q.Get(key); got != want {
+				t.Errorf("%s = %q, want %q", key, got, want)
+			}
+		}
+		page, _ := strconv.Atoi(q.Get("page"))
+		w.Write([]byte(pageJSON(page, 3)))
+	})
+	c.Concurrency = 1
+
+	tracks, err := c.GetRecentTracks(context.Background(), "tk", 100, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(trackNames(tracks)); got != "[Page 1 Page 2 Page 3]" {
+		t.Errorf("tracks = %s", got)
+	}
+}
+
+func TestGetRecentTracksErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantErr  error
+		wantCode int
+		attempts int
+	}{
+		{"invalid api key", http.StatusForbidden, `{"error": 10, "message": "Invalid API key"}`, ErrInvalidApiKey, ErrCodeInvalidApiKey, 1},
+		{"rate limited with 200 status", http.StatusOK, `{"error": 29, "message": "Rate limit exceeded"}`, ErrRateLimitExceeded, ErrCodeRateLimitExceeded, 3},
+		{"server error without body", http.StatusBadGateway, ``, nil, 0, 3},
+		{"not found", http.StatusNotFound, `{"error": 6, "message": "User not found"}`, ErrInvalidParameters, ErrCodeInvalidParameters, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := c.GetRecentTracks(context.Background(), "tk", 0, 0)
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want *Error", err)
+			}
+			if apiErr.Code != tt.wantCode || apiErr.StatusCode != tt.status {
+				t.Errorf("err = %+v", apiErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := int(attempts.Load()); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestGetRecentTracksRetriesTransientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Write([]byte(`{"error": 8, "message": "Operation failed"}`))
+			return
+		}
+		w.Write([]byte(pageJSON(1, 1)))
+	})
+
+	tracks, err := c.GetRecentTracks(context.Background(), "tk", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || attempts.Load() != 2 {
+		t.Errorf("got %d tracks after %d attempts", len(tracks), attempts.Load())
+	}
+}
+
+func TestGetRecentTracksMergesPagesInOrder(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		// Later pages answer first
+		time.Sleep(time.Duration(6-page) * 10 * time.Millisecond)
+		w.Write([]byte(pageJSON(page, 5)))
+	})
+	c.Concurrency = 4
+
+	tracks, err := c.GetRecentTracks(context.Background(), "tk", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(trackNames(tracks)); got != "[Page 1 Page 2 Page 3 Page 4 Page 5]" {
+		t.Errorf("tracks = %s", got)
+	}
+}
+
+func TestGetRecentTracksStopsOnPageError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		switch {
+		case page == 1:
+			w.Write([]byte(pageJSON(page, 50)))
+		case page == 2:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": 10, "message": "Invalid API key"}`))
+		default:
+			// Slow pages only end early when the failed page cancels them
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+				w.Write([]byte(pageJSON(page, 50)))
+			}
+		}
+	})
+	c.Concurrency = 4
+
+	start := time.Now()
+	_, err := c.GetRecentTracks(context.Background(), "tk", 0, 0)
+	if !errors.Is(err, ErrInvalidApiKey) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidApiKey)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %s, other pages were not cancelled", elapsed)
+	}
+}
+
+func TestWalkRecentTracks(t *testing.T) {
+	failPage := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("from") {
+			t.Errorf("from = %q, want none for the full history", r.URL.Query().Get("from"))
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == failPage {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": 10, "message": "Invalid API key"}`))
+			return
+		}
+		w.Write([]byte(pageJSON(page, 3)))
+	})
+
+	var walked []string
+	walk := func(tracks []Track) error {
+		walked = append(walked, trackNames(tracks)...)
+		return nil
+	}
+
+	if err := c.WalkRecentTracks(context.Background(), "tk", 0, 0, walk); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(walked); got != "[Page 3 Page 2 Page 1]" {
+		t.Errorf("walked = %s, want oldest page first", got)
+	}
+
+	// A failure keeps the pages walked so far, which are the oldest ones
+	walked = nil
+	failPage = 2
+	if err := c.WalkRecentTracks(context.Background(), "tk", 0, 0, walk); !errors.Is(err, ErrInvalidApiKey) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidApiKey)
+	}
+	if got := fmt.Sprint(walked); got != "[Page 3]" {
+		t.Errorf("walked = %s before failing", got)
+	}
+}
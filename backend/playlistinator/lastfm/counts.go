@@ -0,0 +1,66 @@
+package lastfm
+
+import "time"
+
+// TrackCount is a track and the number of times it was scrobbled
+type TrackCount struct {
+	Track       Track
+	Count       int
+	FirstPlayed time.Time
+	LastPlayed  time.Time
+}
+
+// KeyFunc returns the identity of a track. Scrobbles with the same key count as one track.
+type KeyFunc func(Track) TrackKey
+
+// CountTracks returns the play count of each distinct track, in order of first appearance.
+// The currently playing track is not a finished play and is left out.
+func CountTracks(tracks []Track) []TrackCount {
+	return CountTracksBy(tracks, Track.Key)
+}
+
+// CountTracksBy is CountTracks with tracks identified by keyOf. Each count keeps the
+// first scrobble seen for its key, which is the newest one in API order.
+func CountTracksBy(tracks []Track, keyOf KeyFunc) []TrackCount {
+	if keyOf == nil {
+		keyOf = Track.Key
+	}
+	index := make(map[TrackKey]int)
+	var trackCounts []TrackCount
+
+	for _, track := range tracks {
+		if track.NowPlaying() {
+			continue
+		}
+		playedAt, _ := track.ScrobbledAt()
+
+		key := keyOf(track)
+		i, ok := index[key]
+		if !ok {
+			i = len(trackCounts)
+			index[key] = i
+			trackCounts = append(trackCounts, TrackCount{Track: track, FirstPlayed: playedAt, LastPlayed: playedAt})
+		}
+
+		tc := &trackCounts[i]
+		tc.Count++
+		if playedAt.Before(tc.FirstPlayed) {
+			tc.FirstPlayed = playedAt
+		}
+		if playedAt.After(tc.LastPlayed) {
+			tc.LastPlayed = playedAt
+		}
+		// Keep MBIDs from whichever scrobble has them, Last.fm doesn't always send them
+		if tc.Track.Mbid == "" {
+			tc.Track.Mbid = track.Mbid
+		}
+		if tc.Track.Artist.Mbid == "" {
+			tc.Track.Artist.Mbid = track.Artist.Mbid
+		}
+		if tc.Track.Album.Mbid == "" {
+			tc.Track.Album.Mbid = track.Album.Mbid
+		}
+	}
+
+	return trackCounts
+}
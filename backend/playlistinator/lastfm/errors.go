@@ -0,0 +1,57 @@
+package lastfm
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error codes from https://www.last.fm/api/errorcodes that the client treats specially
+const (
+	ErrCodeInvalidParameters = 6
+	ErrCodeOperationFailed   = 8
+	ErrCodeInvalidApiKey     = 10
+	ErrCodeServiceOffline    = 11
+	ErrCodeTemporaryError    = 16
+	ErrCodeSuspendedApiKey   = 26
+	ErrCodeRateLimitExceeded = 29
+)
+
+// Sentinels for use with errors.Is, which compares error codes
+var (
+	ErrInvalidParameters = &Error{Code: ErrCodeInvalidParameters}
+	ErrOperationFailed   = &Error{Code: ErrCodeOperationFailed}
+	ErrInvalidApiKey     = &Error{Code: ErrCodeInvalidApiKey}
+	ErrRateLimitExceeded = &Error{Code: ErrCodeRateLimitExceeded}
+)
+
+// Error is a failed Last.fm API call. Code is the API error code from the
+// response body, or zero if the response only had a failing HTTP status.
+type Error struct {
+	Code       int
+	Message    string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("lastfm: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("lastfm: error %d: %s", e.Code, e.Message)
+}
+
+// Is matches errors with the same API error code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != 0 && t.Code == e.Code
+}
+
+// Retryable reports whether the same request may succeed later
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case ErrCodeOperationFailed, ErrCodeServiceOffline, ErrCodeTemporaryError, ErrCodeRateLimitExceeded:
+		return true
+	case 0:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+	}
+	return false
+}
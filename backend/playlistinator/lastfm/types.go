@@ -0,0 +1,165 @@
+package lastfm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Artist as returned in a user.getrecenttracks response. The default format sends
+// {"#text", "mbid"}, extended=1 sends {"name", "mbid", "url", "image"}.
+type Artist struct {
+	Name  string  `json:"#text"`
+	Mbid  string  `json:"mbid"`
+	Url   string  `json:"url,omitempty"`
+	Image []Image `json:"image,omitempty"`
+}
+
+func (a *Artist) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Text  string  `json:"#text"`
+		Name  string  `json:"name"`
+		Mbid  string  `json:"mbid"`
+		Url   string  `json:"url"`
+		Image []Image `json:"image"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("lastfm: decoding artist: %w", err)
+	}
+
+	a.Name = raw.Text
+	if a.Name == "" {
+		a.Name = raw.Name
+	}
+	a.Mbid = raw.Mbid
+	a.Url = raw.Url
+	a.Image = raw.Image
+	return nil
+}
+
+// Album as returned in a user.getrecenttracks response
+type Album struct {
+	Name string `json:"#text"`
+	Mbid string `json:"mbid"`
+}
+
+// Image is one size variant of the track artwork
+type Image struct {
+	Size string `json:"size"`
+	Url  string `json:"#text"`
+}
+
+// Date is the scrobble time. Uts is a unix timestamp in seconds, encoded as a string.
+type Date struct {
+	Uts  string `json:"uts"`
+	Text string `json:"#text"`
+}
+
+// TrackAttr holds the @attr object Last.fm sets on the currently playing track
+type TrackAttr struct {
+	NowPlaying string `json:"nowplaying"`
+}
+
+// Track is a single scrobble from user.getrecenttracks
+type Track struct {
+	Artist     Artist     `json:"artist"`
+	Album      Album      `json:"album"`
+	Name       string     `json:"name"`
+	Mbid       string     `json:"mbid"`
+	Url        string     `json:"url"`
+	Streamable string     `json:"streamable"`
+	Image      []Image    `json:"image"`
+	Date       *Date      `json:"date,omitempty"`
+	Attr       *TrackAttr `json:"@attr,omitempty"`
+
+	// Loved is only set in extended=1 responses, "1" when the user loved the track
+	Loved string `json:"loved,omitempty"`
+}
+
+// NowPlaying reports whether this is the track currently playing rather than a finished scrobble
+func (t Track) NowPlaying() bool {
+	return t.Attr != nil && t.Attr.NowPlaying == "true"
+}
+
+// ScrobbledAt returns when the track was scrobbled. It returns false for the
+// currently playing track, which has no scrobble time yet.
+func (t Track) ScrobbledAt() (time.Time, bool) {
+	if t.Date == nil {
+		return time.Time{}, false
+	}
+	uts, err := strconv.ParseInt(t.Date.Uts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(uts, 0), true
+}
+
+// TrackKey identifies a track independently of when it was scrobbled
+type TrackKey struct {
+	Artist string
+	Album  string
+	Name   string
+}
+
+// Key returns the identity used when counting plays of a track
+func (t Track) Key() TrackKey {
+	return TrackKey{Artist: t.Artist.Name, Album: t.Album.Name, Name: t.Name}
+}
+
+// RecentTracksAttr is the paging information of a user.getrecenttracks response
+type RecentTracksAttr struct {
+	User       string `json:"user"`
+	Page       string `json:"page"`
+	PerPage    string `json:"perPage"`
+	TotalPages string `json:"totalPages"`
+	Total      string `json:"total"`
+}
+
+// TrackList decodes the track field of user.getrecenttracks, which Last.fm sends
+// as an array, as a bare object when a page has exactly one track, or not at all
+type TrackList []Track
+
+func (l *TrackList) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")) || bytes.Equal(data, []byte(`""`)):
+		*l = nil
+		return nil
+
+	case data[0] == '{':
+		var track Track
+		if err := json.Unmarshal(data, &track); err != nil {
+			return fmt.Errorf("lastfm: decoding track: %w", err)
+		}
+		*l = TrackList{track}
+		return nil
+
+	case data[0] == '[':
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("lastfm: decoding track list: %w", err)
+		}
+		tracks := make(TrackList, len(raw))
+		for i, item := range raw {
+			if err := json.Unmarshal(item, &tracks[i]); err != nil {
+				return fmt.Errorf("lastfm: decoding track %d: %w", i, err)
+			}
+		}
+		*l = tracks
+		return nil
+	}
+
+	return fmt.Errorf("lastfm: unexpected track list %.20q", data)
+}
+
+type RecentTracks struct {
+	Track TrackList        `json:"track"`
+	Attr  RecentTracksAttr `json:"@attr"`
+}
+
+type RecentTracksResponse struct {
+	RecentTracks RecentTracks `json:"recenttracks"`
+}
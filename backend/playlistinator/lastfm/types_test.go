@@ -0,0 +1,188 @@
+package lastfm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecentTracksFixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantTracks  int
+		wantPages   string
+		first       TrackKey
+		firstMbid   string
+		nowPlaying  bool
+		scrobbledAt int64 // of the first track that has a date
+	}{
+		{
+			fixture:     "recenttracks_page.json",
+			wantTracks:  3,
+			wantPages:   "3",
+			first:       TrackKey{Artist: "Radiohead", Album: "In Rainbows", Name: "Weird Fishes/Arpeggi"},
+			firstMbid:   "a74b1b7f-71a5-4011-9441-d0b5e4122711",
+			nowPlaying:  true,
+			scrobbledAt: 1728913445,
+		},
+		{
+			fixture:     "recenttracks_single.json",
+			wantTracks:  1,
+			wantPages:   "3",
+			first:       TrackKey{Artist: "Japanese Breakfast", Album: "Jubilee", Name: "Be Sweet"},
+			scrobbledAt: 1728900001,
+		},
+		{
+			fixture:     "recenttracks_extended.json",
+			wantTracks:  2,
+			wantPages:   "1",
+			first:       TrackKey{Artist: "Big Thief", Album: "Dragon New Warm Mountain I Believe in You", Name: "Simulation Swarm"},
+			firstMbid:   "7a3ee4d7-0f0e-4a4c-8f7b-2f1f0e4d3b0a",
+			scrobbledAt: 1728910000,
+		},
+		{
+			fixture:    "recenttracks_empty.json",
+			wantTracks: 0,
+			wantPages:  "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var resp RecentTracksResponse
+			if err := json.Unmarshal(data, &resp); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+
+			tracks := resp.RecentTracks.Track
+			if len(tracks) != tt.wantTracks {
+				t.Fatalf("got %d tracks, want %d", len(tracks), tt.wantTracks)
+			}
+			if got := resp.RecentTracks.Attr.TotalPages; got != tt.wantPages {
+				t.Errorf("TotalPages = %q, want %q", got, tt.wantPages)
+			}
+			if tt.wantTracks == 0 {
+				return
+			}
+
+			if got := tracks[0].Key(); got != tt.first {
+				t.Errorf("first track = %+v, want %+v", got, tt.first)
+			}
+			if got := tracks[0].Artist.Mbid; got != tt.firstMbid {
+				t.Errorf("first artist mbid = %q, want %q", got, tt.firstMbid)
+			}
+			if got := tracks[0].NowPlaying(); got != tt.nowPlaying {
+				t.Errorf("NowPlaying() = %v, want %v", got, tt.nowPlaying)
+			}
+
+			for _, track := range tracks {
+				if playedAt, ok := track.ScrobbledAt(); ok {
+					if playedAt.Unix() != tt.scrobbledAt {
+						t.Errorf("ScrobbledAt() = %d, want %d", playedAt.Unix(), tt.scrobbledAt)
+					}
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTrackListShapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "array", json: `[{"name":"a","artist":{"#text":"x"}},{"name":"b","artist":{"#text":"y"}}]`, want: []string{"a", "b"}},
+		{name: "single object", json: `{"name":"a","artist":{"#text":"x"}}`, want: []string{"a"}},
+		{name: "empty array", json: `[]`, want: nil},
+		{name: "null", json: `null`, want: nil},
+		{name: "empty string", json: `""`, want: nil},
+		{name: "bad element", json: `[{"name":"a"},{"name":5}]`, wantErr: true},
+		{name: "number", json: `5`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list TrackList
+			err := json.Unmarshal([]byte(tt.json), &list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var names []string
+			for _, track := range list {
+				names = append(names, track.Name)
+			}
+			if len(names) != len(tt.want) {
+				t.Fatalf("got %v, want %v", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Errorf("track %d = %q, want %q", i, names[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestArtistFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		wantName string
+		wantMbid string
+	}{
+		{name: "default", json: `{"mbid":"m1","#text":"Radiohead"}`, wantName: "Radiohead", wantMbid: "m1"},
+		{name: "extended", json: `{"url":"https://www.last.fm/music/Bj%C3%B6rk","name":"Björk","image":[],"mbid":"m2"}`, wantName: "Björk", wantMbid: "m2"},
+		{name: "no mbid", json: `{"#text":"Phoebe Bridgers"}`, wantName: "Phoebe Bridgers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var artist Artist
+			if err := json.Unmarshal([]byte(tt.json), &artist); err != nil {
+				t.Fatal(err)
+			}
+			if artist.Name != tt.wantName || artist.Mbid != tt.wantMbid {
+				t.Errorf("got %+v, want name %q mbid %q", artist, tt.wantName, tt.wantMbid)
+			}
+		})
+	}
+}
+
+func TestStoredTrackRoundTrip(t *testing.T) {
+	// Tracks are stored with the default encoding and must decode to the same thing
+	track := Track{
+		Artist: Artist{Name: "Big Thief", Mbid: "m"},
+		Album:  Album{Name: "Capacity"},
+		Name:   "Shark Smile",
+		Date:   &Date{Uts: "1728910000"},
+	}
+	data, err := json.Marshal(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Track
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Key() != track.Key() || decoded.Artist.Mbid != "m" {
+		t.Errorf("round trip gave %+v", decoded)
+	}
+	if playedAt, ok := decoded.ScrobbledAt(); !ok || !playedAt.Equal(time.Unix(1728910000, 0)) {
+		t.Errorf("ScrobbledAt() = %v, %v", playedAt, ok)
+	}
+}
@@ -0,0 +1,222 @@
+// Package matching scores Spotify search results against a Last.fm track
+package matching
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/normalize"
+	"github.com/tejaskoundinya/playlistinator/spotify"
+)
+
+// DefaultThreshold is the lowest score accepted as a match
+const DefaultThreshold = 0.7
+
+// Weights of the parts of a score. Album and duration only count when the target has them.
+const (
+	titleWeight    = 0.5
+	artistWeight   = 0.35
+	albumWeight    = 0.15
+	durationWeight = 0.15
+)
+
+// Words that mark a re-recording by someone else. A candidate using one of them is
+// penalized unless the Last.fm track uses it too. Only whole words count, so
+// "Discovery" is not a cover.
+var impostorWords = regexp.MustCompile(`\b(karaoke|tribute|cover|instrumental|in the style of|made famous by|originally performed)\b`)
+
+// Words that mark a different recording of the same song. The normalizer drops some
+// of them, like "live", so that plays of every version count together, but a live
+// or remixed track is not a match for the studio original. A candidate is penalized
+// when its title and album don't use the same of these words as the target.
+var versionMarkers = regexp.MustCompile(`\b(live|remix|remixed|acoustic|unplugged|demo|karaoke|instrumental|reprise)\b`)
+
+// Factors applied to the score of a likely impostor and of a different version
+const (
+	impostorPenalty = 0.5
+	versionPenalty  = 0.6
+)
+
+// artistSeparators split a credit like "Simon & Garfunkel" or "A, B and C" into its artists
+var artistSeparators = regexp.MustCompile(`(?i)\s*(?:,|&|\+|\band\b|\bwith\b|\bx\b)\s*`)
+
+// Normalizer of Scorers that don't set one, built once since compiling its rules is slow
+var defaultNormalizer = normalize.New(normalize.DefaultRules())
+
+// Target is what a candidate should match. Album and Duration are optional.
+type Target struct {
+	Artist   string
+	Title    string
+	Album    string
+	Duration time.Duration
+}
+
+// Candidate is a scored search result
+type Candidate struct {
+	Uri    string  `json:"uri"`
+	Artist string  `json:"artist"`
+	Name   string  `json:"name"`
+	Album  string  `json:"album,omitempty"`
+	Score  float64 `json:"score"`
+}
+
+// Scorer compares search results with a target. A nil Normalizer uses the default rules.
+type Scorer struct {
+	Normalizer *normalize.Normalizer
+}
+
+// Score rates how well track matches target, from 0 to 1
+func (s Scorer) Score(target Target, track spotify.Track) float64 {
+	n := s.Normalizer
+	if n == nil {
+		n = defaultNormalizer
+	}
+
+	total := titleWeight * similarity(n.Title(target.Title), n.Title(track.Name))
+	weights := titleWeight
+
+	artist := 0.0
+	wantArtist := n.Artist(target.Artist)
+	wantParts := make(map[string]bool)
+	for _, part := range artistSeparators.Split(target.Artist, -1) {
+		wantParts[n.Artist(part)] = true
+	}
+	for _, a := range track.Artists {
+		name := n.Artist(a.Name)
+		artist = max(artist, similarity(wantArtist, name))
+		// "Simon & Garfunkel" on Last.fm vs a track credited to both separately
+		if name != "" && wantParts[name] {
+			artist = max(artist, 0.9)
+		}
+	}
+	total += artistWeight * artist
+	weights += artistWeight
+
+	if target.Album != "" {
+		total += albumWeight * similarity(n.Album(target.Album), n.Album(track.Album.Name))
+		weights += albumWeight
+	}
+
+	if target.Duration > 0 && track.DurationMs > 0 {
+		diff := target.Duration - time.Duration(track.DurationMs)*time.Millisecond
+		if diff < 0 {
+			diff = -diff
+		}
+		// Full marks within 3 seconds, nothing past 30
+		d := 1 - float64(diff-3*time.Second)/float64(27*time.Second)
+		total += durationWeight * min(1, max(0, d))
+		weights += durationWeight
+	}
+
+	score := total / weights
+	if impostor(track, target) {
+		score *= impostorPenalty
+	}
+	if otherVersion(track, target) {
+		score *= versionPenalty
+	}
+	return score
+}
+
+// otherVersion reports whether the candidate and the target are different versions,
+// such as a live recording and the studio one
+func otherVersion(track spotify.Track, target Target) bool {
+	markers := func(s string) map[string]bool {
+		found := make(map[string]bool)
+		for _, word := range versionMarkers.FindAllString(strings.ToLower(s), -1) {
+			if word == "remixed" {
+				word = "remix"
+			}
+			found[word] = true
+		}
+		return found
+	}
+	have := markers(track.Name + " " + track.Album.Name)
+	want := markers(target.Title + " " + target.Album)
+	if len(have) != len(want) {
+		return true
+	}
+	for word := range have {
+		if !want[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// impostor reports whether the candidate looks like a karaoke version or cover the target isn't
+func impostor(track spotify.Track, target Target) bool {
+	candidate := strings.ToLower(track.Name + " " + track.Album.Name)
+	for _, a := range track.Artists {
+		candidate += " " + strings.ToLower(a.Name)
+	}
+	wanted := strings.ToLower(target.Title + " " + target.Album + " " + target.Artist)
+	wantedWords := make(map[string]bool)
+	for _, word := range impostorWords.FindAllString(wanted, -1) {
+		wantedWords[word] = true
+	}
+	for _, word := range impostorWords.FindAllString(candidate, -1) {
+		if !wantedWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// Rank scores every track and returns the candidates best first
+func (s Scorer) Rank(target Target, tracks []spotify.Track) []Candidate {
+	candidates := make([]Candidate, len(tracks))
+	for i, track := range tracks {
+		var artists []string
+		for _, a := range track.Artists {
+			artists = append(artists, a.Name)
+		}
+		candidates[i] = Candidate{
+			Uri:    track.Uri,
+			Artist: strings.Join(artists, ", "),
+			Name:   track.Name,
+			Album:  track.Album.Name,
+			Score:  s.Score(target, track),
+		}
+	}
+	// Stable so that equal scores keep Spotify's order
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// similarity is 1 minus the edit distance relative to the longer string, 1 for equal strings
+func similarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
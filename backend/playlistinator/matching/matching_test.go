@@ -0,0 +1,186 @@
+package matching
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/spotify"
+)
+
+func spotifyTrack(uri, name, album string, durationMs int, artists ...string) spotify.Track {
+	track := spotify.Track{Uri: uri, Name: name, Album: spotify.Album{Name: album}, DurationMs: durationMs}
+	for _, a := range artists {
+		track.Artists = append(track.Artists, spotify.Artist{Name: a})
+	}
+	return track
+}
+
+func TestRankPrefersOriginal(t *testing.T) {
+	target := Target{Artist: "Queen", Title: "Don't Stop Me Now", Album: "Jazz"}
+	tracks := []spotify.Track{
+		spotifyTrack("karaoke", "Don't Stop Me Now (Karaoke Version)", "Karaoke Hits", 0, "Karaoke All Stars"),
+		spotifyTrack("cover", "Don't Stop Me Now", "Covers", 0, "Some Band"),
+		spotifyTrack("original", "Don't Stop Me Now - Remastered 2011", "Jazz (2011 Remaster)", 0, "Queen"),
+		spotifyTrack("other", "Bicycle Race", "Jazz", 0, "Queen"),
+	}
+
+	candidates := Scorer{}.Rank(target, tracks)
+	if candidates[0].Uri != "original" {
+		t.Fatalf("best = %+v", candidates[0])
+	}
+	if candidates[0].Score < 0.99 {
+		t.Errorf("original scored %v", candidates[0].Score)
+	}
+	for _, c := range candidates[1:] {
+		if c.Score >= DefaultThreshold {
+			t.Errorf("%s scored %v, above the threshold", c.Uri, c.Score)
+		}
+	}
+}
+
+func TestRankPrefersStudioVersion(t *testing.T) {
+	target := Target{Artist: "Queen", Title: "Bohemian Rhapsody", Album: "A Night at the Opera"}
+	tracks := []spotify.Track{
+		spotifyTrack("live", "Bohemian Rhapsody - Live at Wembley '86", "Live at Wembley '86", 0, "Queen"),
+		spotifyTrack("remix", "Bohemian Rhapsody - Remix", "A Night at the Opera", 0, "Queen"),
+		spotifyTrack("acoustic", "Bohemian Rhapsody (Acoustic)", "Acoustic Sessions", 0, "Queen"),
+		spotifyTrack("studio", "Bohemian Rhapsody - Remastered 2011", "A Night at the Opera (2011 Remaster)", 0, "Queen"),
+	}
+
+	candidates := Scorer{}.Rank(target, tracks)
+	if candidates[0].Uri != "studio" {
+		t.Fatalf("best = %+v", candidates[0])
+	}
+	for _, c := range candidates[1:] {
+		if c.Score >= DefaultThreshold {
+			t.Errorf("%s scored %v, above the threshold", c.Uri, c.Score)
+		}
+	}
+
+	// The live version is the match when the live version was played
+	live := Target{Artist: "Queen", Title: "Bohemian Rhapsody - Live", Album: "Live at Wembley '86"}
+	if best := (Scorer{}).Rank(live, tracks)[0]; best.Uri != "live" || best.Score < DefaultThreshold {
+		t.Errorf("best for the live target = %+v", best)
+	}
+}
+
+func TestScoreParts(t *testing.T) {
+	s := Scorer{}
+	tests := []struct {
+		name   string
+		target Target
+		track  spotify.Track
+		min    float64
+		max    float64
+	}{
+		{
+			name:   "featured artist credited separately",
+			target: Target{Artist: "Simon & Garfunkel", Title: "The Boxer"},
+			track:  spotifyTrack("", "The Boxer", "", 0, "Simon"),
+			min:    0.9,
+			max:    1,
+		},
+		{
+			name:   "duration close",
+			target: Target{Artist: "A", Title: "Song", Duration: 200 * time.Second},
+			track:  spotifyTrack("", "Song", "", 201000, "A"),
+			min:    1,
+			max:    1,
+		},
+		{
+			name:   "duration far off",
+			target: Target{Artist: "A", Title: "Song", Duration: 200 * time.Second},
+			track:  spotifyTrack("", "Song", "", 400000, "A"),
+			min:    0.8,
+			max:    0.9,
+		},
+		{
+			name:   "karaoke wanted",
+			target: Target{Artist: "Karaoke All Stars", Title: "Song (Karaoke Version)"},
+			track:  spotifyTrack("", "Song (Karaoke Version)", "", 0, "Karaoke All Stars"),
+			min:    1,
+			max:    1,
+		},
+		{
+			name:   "artist name inside another",
+			target: Target{Artist: "Queens of the Stone Age", Title: "No One Knows"},
+			track:  spotifyTrack("", "No One Knows", "", 0, "Queen"),
+			max:    DefaultThreshold,
+		},
+		{
+			name:   "one letter artist",
+			target: Target{Artist: "Yeah Yeah Yeahs", Title: "Maps"},
+			track:  spotifyTrack("", "Maps", "", 0, "Y"),
+			max:    DefaultThreshold,
+		},
+		{
+			name:   "one of several credited artists",
+			target: Target{Artist: "Calvin Harris, Dua Lipa", Title: "One Kiss"},
+			track:  spotifyTrack("", "One Kiss", "", 0, "Dua Lipa", "Calvin Harris"),
+			min:    0.95,
+			max:    1,
+		},
+		{
+			name:   "cover inside a word",
+			target: Target{Artist: "Daft Punk", Title: "One More Time", Album: "Discovery"},
+			track:  spotifyTrack("", "One More Time", "Discovery", 0, "Daft Punk"),
+			min:    1,
+			max:    1,
+		},
+		{
+			name:   "cover version",
+			target: Target{Artist: "Eminem", Title: "Not Afraid", Album: "Recovery"},
+			track:  spotifyTrack("", "Not Afraid (Cover Version)", "Recovery", 0, "Eminem"),
+			max:    0.5,
+		},
+		{
+			name:   "live version of a studio track",
+			target: Target{Artist: "Queen", Title: "Bohemian Rhapsody"},
+			track:  spotifyTrack("", "Bohemian Rhapsody - Live at Wembley '86", "", 0, "Queen"),
+			max:    DefaultThreshold,
+		},
+		{
+			name:   "remix of a studio track",
+			target: Target{Artist: "A", Title: "Song"},
+			track:  spotifyTrack("", "Song - Remix", "", 0, "A"),
+			max:    DefaultThreshold,
+		},
+		{
+			name:   "acoustic version of a studio track",
+			target: Target{Artist: "A", Title: "Song"},
+			track:  spotifyTrack("", "Song (Acoustic)", "", 0, "A"),
+			max:    DefaultThreshold,
+		},
+		{
+			name:   "remixed wanted",
+			target: Target{Artist: "A", Title: "Song (Remix)"},
+			track:  spotifyTrack("", "Song - Remixed", "", 0, "A"),
+			min:    0.9,
+			max:    1,
+		},
+		{
+			name:   "different song",
+			target: Target{Artist: "A", Title: "Yellow"},
+			track:  spotifyTrack("", "Purple Rain", "", 0, "B"),
+			max:    0.3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Score(tt.target, tt.track)
+			if got < tt.min-1e-9 || got > tt.max+1e-9 {
+				t.Errorf("Score = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if got := similarity("kitten", "sitting"); got < 0.57 || got > 0.58 {
+		t.Errorf("similarity = %v", got)
+	}
+	if similarity("", "") != 1 || similarity("a", "") != 0 {
+		t.Error("empty strings")
+	}
+}
@@ -0,0 +1,145 @@
+// Package musicbrainz is a small client for the MusicBrainz API, used to turn the
+// recording MBIDs Last.fm attaches to scrobbles into ISRCs.
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/tejaskoundinya/playlistinator/internal/ratelimit"
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+)
+
+const (
+	DefaultBaseURL = "https://musicbrainz.org/ws/2"
+
+	// MusicBrainz allows one request per second per client
+	MaxRequestsPerSecond = 1
+
+	// MusicBrainz asks every client to identify itself with a contact address
+	DefaultUserAgent = "playlistinator/1.0 ( https://github.com/tejaskoundinya/playlistinator )"
+)
+
+// MusicBrainz rate limits by address, so all clients in a process share one limiter by default
+var defaultLimiter = ratelimit.New(MaxRequestsPerSecond)
+
+// ErrNotFound is returned when MusicBrainz has no recording with the MBID
+var ErrNotFound = errors.New("musicbrainz: not found")
+
+// Error is any other failed API call
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("musicbrainz: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("musicbrainz: %d %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the same request may succeed later. MusicBrainz
+// answers 503 when a client goes over the rate limit.
+func (e *Error) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Client talks to the MusicBrainz API. The zero value is not usable, use NewClient.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+
+	// Retry controls how rate limit and server errors are retried
+	Retry retry.Policy
+
+	// Limiter spaces out requests. A nil limiter does not limit.
+	Limiter *ratelimit.Limiter
+}
+
+// NewClient returns a client for the public MusicBrainz API
+func NewClient() *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: http.DefaultClient,
+		UserAgent:  DefaultUserAgent,
+		Retry:      retry.DefaultPolicy,
+		Limiter:    defaultLimiter,
+	}
+}
+
+// Recording is the part of a MusicBrainz recording the client uses
+type Recording struct {
+	Id     string   `json:"id"`
+	Title  string   `json:"title"`
+	Length int      `json:"length"`
+	Isrcs  []string `json:"isrcs"`
+}
+
+// Recording looks up a recording by MBID, including its ISRCs
+func (c *Client) Recording(ctx context.Context, mbid string) (*Recording, error) {
+	params := url.Values{}
+	params.Set("inc", "isrcs")
+	params.Set("fmt", "json")
+	path := "/recording/" + url.PathEscape(mbid) + "?" + params.Encode()
+
+	var recording Recording
+	err := c.Retry.Do(ctx, func(ctx context.Context) error {
+		return c.get(ctx, path, &recording)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("musicbrainz: looking up recording %s: %w", mbid, err)
+	}
+	return &recording, nil
+}
+
+// Isrcs returns the ISRCs of the recording with the MBID, which may be none
+func (c *Client) Isrcs(ctx context.Context, mbid string) ([]string, error) {
+	recording, err := c.Recording(ctx, mbid)
+	if err != nil {
+		return nil, err
+	}
+	return recording.Isrcs, nil
+}
+
+// get makes a single rate limited API call and decodes the response into out
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Errors come back as {"error": "..."}
+		var apiError struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiError)
+		return &Error{StatusCode: resp.StatusCode, Message: apiError.Error}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
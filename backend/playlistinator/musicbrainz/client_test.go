@@ -0,0 +1,86 @@
+package musicbrainz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+)
+
+// newTestClient returns a client for a local stand-in of the MusicBrainz API
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewClient()
+	c.BaseURL = server.URL
+	c.Limiter = nil
+	c.Retry = retry.Policy{MaxAttempts: 2}
+	return c
+}
+
+func TestIsrcs(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/recording/b1a9c0e9-d987-4042-ae91-78d6a3267d69" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("inc"); got != "isrcs" {
+			t.Errorf("inc = %q", got)
+		}
+		if r.Header.Get("User-Agent") != DefaultUserAgent {
+			t.Errorf("user agent = %q", r.Header.Get("User-Agent"))
+		}
+		w.Write([]byte(`{"id": "b1a9c0e9-d987-4042-ae91-78d6a3267d69", "title": "Let Down", "length": 299000, "isrcs": ["GBAYE9700132", "GBAYE1700345"]}`))
+	})
+
+	isrcs, err := c.Isrcs(context.Background(), "b1a9c0e9-d987-4042-ae91-78d6a3267d69")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"GBAYE9700132", "GBAYE1700345"}; !reflect.DeepEqual(isrcs, want) {
+		t.Errorf("isrcs = %v, want %v", isrcs, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantErr  error
+		attempts int
+	}{
+		{"not found", http.StatusNotFound, ErrNotFound, 1},
+		{"bad request", http.StatusBadRequest, nil, 1},
+		{"rate limited", http.StatusServiceUnavailable, nil, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"error": "nope"}`))
+			})
+
+			_, err := c.Isrcs(context.Background(), "mbid")
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			var apiErr *Error
+			if tt.wantErr == nil && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+				t.Errorf("err = %v, want status %d", err, tt.status)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Package normalize folds the different spellings Last.fm reports for the same
+// song into one canonical track identity
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// DefaultVersions are the words that mark a title or album suffix as a version of
+// the same recording, as in "Song (Remastered 2011)" or "Song - Radio Edit"
+var DefaultVersions = []string{
+	"remaster", "remastered", "live", "edit", "single version", "album version",
+	"mono", "stereo", "deluxe", "expanded", "anniversary", "bonus track",
+}
+
+// Rules select which differences are ignored when identifying a track
+type Rules struct {
+	// Unicode applies NFKC, so full-width and compatibility characters match their plain forms
+	Unicode bool `yaml:"unicode"`
+	// Accents strips diacritics, "Björk" matches "Bjork"
+	Accents bool `yaml:"accents"`
+	// Case folds upper and lower case
+	Case bool `yaml:"case"`
+	// Punctuation ignores punctuation and symbols, "Don't Stop" matches "Dont Stop!"
+	Punctuation bool `yaml:"punctuation"`
+	// Featuring drops "feat." credits from titles and artists
+	Featuring bool `yaml:"featuring"`
+	// Versions drops suffixes containing one of VersionWords from titles and albums
+	Versions bool `yaml:"versions"`
+	// VersionWords replaces DefaultVersions when set
+	VersionWords []string `yaml:"version_words"`
+	// Album ignores the album, so plays of the single and the album version add up
+	Album bool `yaml:"album"`
+}
+
+// DefaultRules enables every rule
+func DefaultRules() Rules {
+	return Rules{
+		Unicode:     true,
+		Accents:     true,
+		Case:        true,
+		Punctuation: true,
+		Featuring:   true,
+		Versions:    true,
+		Album:       true,
+	}
+}
+
+var (
+	// Credits in brackets anywhere, or trailing after the title or artist
+	featBracket  = regexp.MustCompile(`(?i)\s*[(\[](?:feat\.?|ft\.?|featuring)\s[^)\]]*[)\]]`)
+	featTrailing = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring)\s.*$`)
+
+	// Any bracketed group, and a " - suffix" at the end
+	bracketGroup = regexp.MustCompile(`\s*[(\[]([^)\]]*)[)\]]`)
+	dashSuffix   = regexp.MustCompile(`\s+[-–—]\s+(.*)$`)
+)
+
+// Normalizer applies a set of rules. Build one with New, it is safe for concurrent use.
+type Normalizer struct {
+	rules    Rules
+	versions *regexp.Regexp
+}
+
+// New returns a normalizer for the rules
+func New(rules Rules) *Normalizer {
+	words := rules.VersionWords
+	if len(words) == 0 {
+		words = DefaultVersions
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+
+	return &Normalizer{
+		rules:    rules,
+		versions: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+// Key returns the canonical identity of a track
+func (n *Normalizer) Key(track lastfm.Track) lastfm.TrackKey {
+	key := lastfm.TrackKey{
+		Artist: n.Artist(track.Artist.Name),
+		Name:   n.Title(track.Name),
+	}
+	if !n.rules.Album {
+		key.Album = n.Album(track.Album.Name)
+	}
+	return key
+}
+
+// Artist normalizes an artist name
+func (n *Normalizer) Artist(name string) string {
+	name = n.unicode(name)
+	if n.rules.Featuring {
+		name = stripFeaturing(name)
+	}
+	return n.clean(name)
+}
+
+// Title normalizes a track title
+func (n *Normalizer) Title(name string) string {
+	name = n.unicode(name)
+	if n.rules.Featuring {
+		name = stripFeaturing(name)
+	}
+	if n.rules.Versions {
+		name = n.stripVersions(name)
+	}
+	return n.clean(name)
+}
+
+// Album normalizes an album title
+func (n *Normalizer) Album(name string) string {
+	name = n.unicode(name)
+	if n.rules.Versions {
+		name = n.stripVersions(name)
+	}
+	return n.clean(name)
+}
+
+func (n *Normalizer) unicode(s string) string {
+	if n.rules.Unicode {
+		s = norm.NFKC.String(s)
+	}
+	return s
+}
+
+func stripFeaturing(s string) string {
+	s = featBracket.ReplaceAllString(s, "")
+	return featTrailing.ReplaceAllString(s, "")
+}
+
+// StripFeaturing removes "feat." credits from a title or artist, keeping everything else as it is
+func StripFeaturing(s string) string {
+	return strings.TrimSpace(stripFeaturing(s))
+}
+
+// StripBrackets removes every bracketed group and a trailing " - suffix" from a title,
+// "Song (Live) - 2011 Remaster" becomes "Song". Titles that are all brackets are kept.
+func StripBrackets(s string) string {
+	stripped := bracketGroup.ReplaceAllString(s, "")
+	if m := dashSuffix.FindStringIndex(stripped); m != nil {
+		stripped = stripped[:m[0]]
+	}
+	if stripped = strings.TrimSpace(stripped); stripped == "" {
+		return s
+	}
+	return stripped
+}
+
+// stripVersions drops bracketed groups and dash suffixes that name a version. Other
+// brackets, like "(Don't Fear) The Reaper", are kept.
+func (n *Normalizer) stripVersions(s string) string {
+	s = bracketGroup.ReplaceAllStringFunc(s, func(group string) string {
+		if n.versions.MatchString(group) {
+			return ""
+		}
+		return group
+	})
+	if m := dashSuffix.FindStringSubmatchIndex(s); m != nil && n.versions.MatchString(s[m[2]:m[3]]) {
+		s = s[:m[0]]
+	}
+	return s
+}
+
+// clean applies the character level rules and collapses whitespace
+func (n *Normalizer) clean(s string) string {
+	if n.rules.Accents {
+		stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+		if err == nil {
+			s = stripped
+		}
+	}
+	if n.rules.Case {
+		// Casers keep state, so each call gets its own
+		s = cases.Fold().String(s)
+	}
+	if n.rules.Punctuation {
+		s = strings.Map(func(r rune) rune {
+			switch {
+			case r == '\'' || r == '’' || r == '`':
+				// Apostrophes join words, "don't" becomes "dont"
+				return -1
+			case r == '&':
+				return r
+			case unicode.IsPunct(r) || unicode.IsSymbol(r):
+				return ' '
+			}
+			return r
+		}, s)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
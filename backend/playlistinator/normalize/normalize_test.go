@@ -0,0 +1,145 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+func track(artist, album, name string) lastfm.Track {
+	return lastfm.Track{Artist: lastfm.Artist{Name: artist}, Album: lastfm.Album{Name: album}, Name: name}
+}
+
+func TestTitle(t *testing.T) {
+	n := New(DefaultRules())
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Song", "song"},
+		{"SONG", "song"},
+		{"Song (Remastered 2011)", "song"},
+		{"Song - 2011 Remaster", "song"},
+		{"Song - Radio Edit", "song"},
+		{"Song [Live at Wembley]", "song"},
+		{"Song (Single Version)", "song"},
+		{"Song (feat. Someone Else)", "song"},
+		{"Song [ft. Someone]", "song"},
+		{"Song feat. Someone", "song"},
+		{"Song (feat. Someone) - Remastered", "song"},
+		{"Don't Stop Me Now", "dont stop me now"},
+		{"Don’t Stop Me Now!", "dont stop me now"},
+		{"Jóga", "joga"},
+		{"Ｓｏｎｇ", "song"},
+		{"  Song   Title ", "song title"},
+		// Brackets and dashes that don't name a version are part of the title
+		{"(Don't Fear) The Reaper", "dont fear the reaper"},
+		{"Song (Remix)", "song remix"},
+		{"Song - Acoustic", "song acoustic"},
+	}
+
+	for _, tt := range tests {
+		if got := n.Title(tt.in); got != tt.want {
+			t.Errorf("Title(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestArtist(t *testing.T) {
+	n := New(DefaultRules())
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Björk", "bjork"},
+		{"Beyoncé feat. JAY-Z", "beyonce"},
+		{"Simon & Garfunkel", "simon & garfunkel"},
+		{"AC/DC", "ac dc"},
+	}
+
+	for _, tt := range tests {
+		if got := n.Artist(tt.in); got != tt.want {
+			t.Errorf("Artist(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	same := []lastfm.Track{
+		track("Radiohead", "OK Computer", "Let Down"),
+		track("radiohead", "OK Computer OKNOTOK 1997 2017", "Let Down (Remastered)"),
+		track("RADIOHEAD", "", "Let Down - Remastered"),
+	}
+
+	n := New(DefaultRules())
+	for _, tr := range same[1:] {
+		if n.Key(tr) != n.Key(same[0]) {
+			t.Errorf("Key(%+v) = %+v, want %+v", tr, n.Key(tr), n.Key(same[0]))
+		}
+	}
+
+	// Keeping albums apart still folds album versions
+	rules := DefaultRules()
+	rules.Album = false
+	n = New(rules)
+	if a, b := n.Key(track("A", "Album", "Song")), n.Key(track("A", "Album (Deluxe Edition)", "Song")); a != b {
+		t.Errorf("deluxe album: %+v != %+v", a, b)
+	}
+	if a, b := n.Key(track("A", "Album", "Song")), n.Key(track("A", "Single", "Song")); a == b {
+		t.Errorf("different albums share key %+v", a)
+	}
+}
+
+func TestRulesCanBeDisabled(t *testing.T) {
+	n := New(Rules{})
+	if got := n.Title("Song (Remastered 2011)"); got != "Song (Remastered 2011)" {
+		t.Errorf("Title with no rules = %q", got)
+	}
+
+	rules := DefaultRules()
+	rules.VersionWords = []string{"acoustic"}
+	n = New(rules)
+	if got := n.Title("Song - Acoustic"); got != "song" {
+		t.Errorf("custom version word: %q", got)
+	}
+	if got := n.Title("Song (Remastered)"); got != "song remastered" {
+		t.Errorf("default words still applied: %q", got)
+	}
+}
+
+func TestCountTracksBy(t *testing.T) {
+	tracks := []lastfm.Track{
+		track("Queen", "Jazz", "Don't Stop Me Now - Remastered 2011"),
+		track("Queen", "Greatest Hits", "Don't Stop Me Now"),
+		track("queen", "Jazz", "Dont Stop Me Now"),
+		track("Queen", "Jazz", "Bicycle Race"),
+	}
+
+	counts := lastfm.CountTracksBy(tracks, New(DefaultRules()).Key)
+	if len(counts) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(counts))
+	}
+	if counts[0].Count != 3 || counts[0].Track.Name != "Don't Stop Me Now - Remastered 2011" {
+		t.Errorf("first = %+v", counts[0])
+	}
+}
+
+func TestStripHelpers(t *testing.T) {
+	tests := []struct {
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{StripBrackets, "Song (Live) - 2011 Remaster", "Song"},
+		{StripBrackets, "(Don't Fear) The Reaper", "The Reaper"},
+		{StripBrackets, "[Untitled]", "[Untitled]"},
+		{StripBrackets, "Song", "Song"},
+		{StripFeaturing, "Song (feat. Someone) - Radio Edit", "Song - Radio Edit"},
+		{StripFeaturing, "Beyoncé feat. JAY-Z", "Beyoncé"},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("strip(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+var spotifyTrackId = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+// Function to turn a Spotify track URI, open.spotify.com link or bare track ID into a URI
+func ParseSpotifyTrackUri(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	id := value
+	switch {
+	case strings.HasPrefix(value, "spotify:track:"):
+		id = strings.TrimPrefix(value, "spotify:track:")
+	case strings.HasPrefix(value, "https://open.spotify.com/"):
+		u, err := url.Parse(value)
+		if err != nil {
+			return "", err
+		}
+		// Links can carry a locale, as in /intl-de/track/ID
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-2] != "track" {
+			return "", fmt.Errorf("%q is not a link to a Spotify track", value)
+		}
+		id = parts[len(parts)-1]
+	}
+	if !spotifyTrackId.MatchString(id) {
+		return "", fmt.Errorf("%q is not a Spotify track URI, link or ID", value)
+	}
+	return "spotify:track:" + id, nil
+}
+
+const overrideUsage = `usage: playlistinator override <command>
+
+commands:
+  add <artist> <track> <spotify uri>  always use this Spotify track
+  add -skip <artist> <track>          never add this track to a playlist
+  list                                show every override
+  remove <artist> <track>             delete an override`
+
+// Function to add, list or remove manual match overrides
+func runOverrideCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(overrideUsage)
+	}
+
+	db, err := OpenStoreFromEnv()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "add":
+		flags := flag.NewFlagSet("override add", flag.ContinueOnError)
+		skip := flags.Bool("skip", false, "Skip the track instead of pinning a URI")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		override := store.Override{Skip: *skip}
+		switch {
+		case *skip && flags.NArg() == 2:
+		case !*skip && flags.NArg() == 3:
+			if override.Uri, err = ParseSpotifyTrackUri(flags.Arg(2)); err != nil {
+				return err
+			}
+		default:
+			return errors.New(overrideUsage)
+		}
+		override.Artist, override.Track = flags.Arg(0), flags.Arg(1)
+		if err := db.PutOverride(override); err != nil {
+			return err
+		}
+		fmt.Printf("Saved override for %s - %s\n", override.Artist, override.Track)
+		return nil
+
+	case "list":
+		return listOverrides(db, os.Stdout)
+
+	case "remove":
+		if len(args) != 3 {
+			return errors.New(overrideUsage)
+		}
+		found, err := db.DeleteOverride(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("no override for %s - %s", args[1], args[2])
+		}
+		fmt.Printf("Removed override for %s - %s\n", args[1], args[2])
+		return nil
+	}
+
+	return errors.New(overrideUsage)
+}
+
+// Function to print the overrides as a table
+func listOverrides(db *store.DB, w io.Writer) error {
+	overrides, err := db.Overrides()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ARTIST\tTRACK\tOVERRIDE\t")
+	for _, o := range overrides {
+		result := o.Uri
+		if o.Skip {
+			result = "skip"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", o.Artist, o.Track, result)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d overrides\n", len(overrides))
+	return nil
+}
+
+// API request body for adding an override. Either Uri or Skip must be set.
+type OverrideRequest struct {
+	Artist string `json:"artist"`
+	Track  string `json:"track"`
+	Uri    string `json:"uri"`
+	Skip   bool   `json:"skip"`
+}
+
+// API response for the overrides endpoints
+type OverridesResponse struct {
+	Success   bool             `json:"success"`
+	Overrides []store.Override `json:"overrides"`
+}
+
+// API handler for overrides. GET lists them, POST adds one and DELETE removes the
+// one named by the artist and track query parameters.
+func handleOverrides(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if db == nil {
+			writeError(w, fmt.Errorf("%w: overrides need the local database, see PLAYLISTINATOR_DB", errNotConfigured))
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+		case "POST":
+			var body OverrideRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+				return
+			}
+			if body.Artist == "" || body.Track == "" || (body.Uri == "") == !body.Skip {
+				writeError(w, fmt.Errorf("%w: artist, track and either uri or skip are required", errBadRequest))
+				return
+			}
+			override := store.Override{Artist: body.Artist, Track: body.Track, Skip: body.Skip}
+			if !body.Skip {
+				uri, err := ParseSpotifyTrackUri(body.Uri)
+				if err != nil {
+					writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+					return
+				}
+				override.Uri = uri
+			}
+			if err := db.PutOverride(override); err != nil {
+				writeError(w, err)
+				return
+			}
+		case "DELETE":
+			artist, track := r.URL.Query().Get("artist"), r.URL.Query().Get("track")
+			if artist == "" || track == "" {
+				writeError(w, fmt.Errorf("%w: artist and track query parameters are required", errBadRequest))
+				return
+			}
+			found, err := db.DeleteOverride(artist, track)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if !found {
+				writeError(w, fmt.Errorf("%w: no override for %s - %s", errNotFound, artist, track))
+				return
+			}
+		default:
+			writeError(w, fmt.Errorf("%w: %s", errMethodNotAllowed, r.Method))
+			return
+		}
+
+		// Every method answers with the overrides as they are now
+		overrides, err := db.Overrides()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(OverridesResponse{Success: true, Overrides: overrides})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSpotifyTrackUri(t *testing.T) {
+	const id = "4uLU6hMCjMI75M1A2tKUQC"
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   bool
+	}{
+		{"uri", "spotify:track:" + id, "spotify:track:" + id, false},
+		{"link", "https://open.spotify.com/track/" + id + "?si=abc", "spotify:track:" + id, false},
+		{"localized link", "https://open.spotify.com/intl-de/track/" + id, "spotify:track:" + id, false},
+		{"bare id", "  " + id + "\n", "spotify:track:" + id, false},
+		{"album link", "https://open.spotify.com/album/" + id, "", true},
+		{"album uri", "spotify:album:" + id, "", true},
+		{"short id", "spotify:track:4uLU6hMC", "", true},
+		{"garbage", "never gonna give you up", "", true},
+		{"empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSpotifyTrackUri(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleOverrides(t *testing.T) {
+	db := openTestStore(t)
+	handler := handleOverrides(db)
+
+	serve := func(method string, target string, body string) (*httptest.ResponseRecorder, GenerateResponse, OverridesResponse) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var failed GenerateResponse
+		var ok OverridesResponse
+		if w.Code == http.StatusOK {
+			json.Unmarshal(w.Body.Bytes(), &ok)
+		} else {
+			json.Unmarshal(w.Body.Bytes(), &failed)
+		}
+		return w, failed, ok
+	}
+
+	w, _, ok := serve("POST", "/api/overrides", `{"artist": "Queen", "track": "Bohemian Rhapsody", "uri": "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"}`)
+	if w.Code != http.StatusOK || len(ok.Overrides) != 1 || ok.Overrides[0].Uri != "spotify:track:4uLU6hMCjMI75M1A2tKUQC" {
+		t.Fatalf("POST = %d %+v", w.Code, ok)
+	}
+
+	w, failed, _ := serve("POST", "/api/overrides", `{"artist": "Queen", "track": "Bohemian Rhapsody", "uri": "nope"}`)
+	if w.Code != http.StatusBadRequest || failed.Error == nil || failed.Error.Code != "bad_request" {
+		t.Errorf("POST with a bad uri = %d %+v", w.Code, failed.Error)
+	}
+
+	w, failed, _ = serve("DELETE", "/api/overrides?artist=Queen&track=Radio+Ga+Ga", "")
+	if w.Code != http.StatusNotFound || failed.Error == nil || failed.Error.Code != "not_found" {
+		t.Errorf("DELETE of a missing override = %d %+v", w.Code, failed.Error)
+	}
+
+	w, _, ok = serve("DELETE", "/api/overrides?artist=queen&track=Bohemian+Rhapsody+(Remastered+2011)", "")
+	if w.Code != http.StatusOK || len(ok.Overrides) != 0 {
+		t.Errorf("DELETE = %d %+v", w.Code, ok)
+	}
+
+	w, _, ok = serve("GET", "/api/overrides", "")
+	if w.Code != http.StatusOK || !ok.Success || len(ok.Overrides) != 0 {
+		t.Errorf("GET = %d %+v", w.Code, ok)
+	}
+
+	// Without a database the endpoint is not configured rather than broken
+	w = httptest.NewRecorder()
+	handleOverrides(nil)(w, httptest.NewRequest("GET", "/api/overrides", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET without a database = %d", w.Code)
+	}
+}
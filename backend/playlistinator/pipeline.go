@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/matching"
+	"github.com/tejaskoundinya/playlistinator/musicbrainz"
+	"github.com/tejaskoundinya/playlistinator/normalize"
+	"github.com/tejaskoundinya/playlistinator/ranking"
+	"github.com/tejaskoundinya/playlistinator/spotify"
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// Options controls what a single pipeline run generates
+type Options struct {
+	// Window is how far back to rank from To. From overrides it when set.
+	Window time.Duration
+	// From and To bound the ranked scrobbles. A zero To means now.
+	From time.Time
+	To   time.Time
+
+	Limit        int
+	PlaylistName string
+	Public       bool
+
+	// Description is a text/template for the playlist description, see DescriptionData.
+	// Empty means DefaultDescription.
+	Description string
+
+	// Ranking is the strategy used to order tracks, one of ranking.Names()
+	Ranking string
+	// HalfLife is used by the decay ranking, zero means ranking.DefaultHalfLife
+	HalfLife time.Duration
+	// Normalize decides which spellings of a track count as the same track
+	Normalize normalize.Rules
+
+	// MaxPerArtist, MaxPerAlbum and MinArtists limit how much of the playlist one
+	// artist or album can take. Zero means no limit.
+	MaxPerArtist int
+	MaxPerAlbum  int
+	MinArtists   int
+
+	// MinPlays leaves out tracks played fewer times in the window
+	MinPlays int
+	// Exclude keeps matching tracks out of the playlist
+	Exclude ExcludeRules
+
+	// Offline ranks scrobbles from the local store instead of fetching them from Last.fm
+	Offline bool
+}
+
+// DefaultOptions are the settings of the original "TK - Hot 100" playlist
+func DefaultOptions() Options {
+	return Options{
+		Window:       30 * 24 * time.Hour,
+		Limit:        100,
+		PlaylistName: "TK - Hot 100",
+		Public:       false,
+		Ranking:      ranking.Count,
+		Normalize:    normalize.DefaultRules(),
+	}
+}
+
+// ResolvedTrack is a ranked track that was found on Spotify
+type ResolvedTrack struct {
+	Rank   int     `json:"rank"`
+	Artist string  `json:"artist"`
+	Name   string  `json:"name"`
+	Album  string  `json:"album,omitempty"`
+	Mbid   string  `json:"mbid,omitempty"`
+	Plays  int     `json:"plays"`
+	Score  float64 `json:"score"`
+	Uri    string  `json:"uri"`
+
+	// Confidence is how well the Spotify track matched, from 0 to 1
+	Confidence   float64              `json:"confidence"`
+	Alternatives []matching.Candidate `json:"alternatives,omitempty"`
+	// Method is the search that found the track, "strict" unless a fallback was needed
+	Method string `json:"method"`
+}
+
+// UnmatchedTrack is a ranked track that could not be found on Spotify
+type UnmatchedTrack struct {
+	Rank   int     `json:"rank"`
+	Artist string  `json:"artist"`
+	Name   string  `json:"name"`
+	Album  string  `json:"album,omitempty"`
+	Mbid   string  `json:"mbid,omitempty"`
+	Plays  int     `json:"plays"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+
+	// Candidates are the search results that scored below the match threshold
+	Candidates []matching.Candidate `json:"candidates,omitempty"`
+
+	// err is why the track was left out, to tell misses from tracks the user excluded
+	err error
+}
+
+// StepTiming is how long one pipeline step took
+type StepTiming struct {
+	Step     string        `json:"step"`
+	Duration time.Duration `json:"-"`
+	Millis   int64         `json:"ms"`
+}
+
+// Result describes what a pipeline run did
+type Result struct {
+	PlaylistId   string `json:"playlistId"`
+	PlaylistName string `json:"playlistName"`
+	Scrobbles    int    `json:"scrobbles"`
+	UniqueTracks int    `json:"uniqueTracks"`
+	Excluded     int    `json:"excluded"`
+	// FallbackMatches counts tracks only found by a fallback search
+	FallbackMatches int              `json:"fallbackMatches"`
+	Matched         []ResolvedTrack  `json:"matched"`
+	Unmatched       []UnmatchedTrack `json:"unmatched"`
+	Timings         []StepTiming     `json:"timings"`
+}
+
+// Pipeline turns a user's Last.fm history into a Spotify playlist
+type Pipeline struct {
+	LastFm     *lastfm.Client
+	LastFmUser string
+	Spotify    *spotify.Client
+	Resolver   *Resolver
+
+	// Store holds synced scrobbles for offline runs. May be nil.
+	Store *store.DB
+
+	// SearchWorkers is the number of Spotify searches run in parallel
+	SearchWorkers int
+
+	// ReviewThreshold is the confidence below which matches are reported for review
+	ReviewThreshold float64
+
+	// Progress receives step by step output for the CLI. Nothing is written when nil.
+	Progress io.Writer
+}
+
+// Function to build the MusicBrainz client used for ISRC matching. MUSICBRAINZ_URL
+// points it at a mirror, and it is nil when MUSICBRAINZ_DISABLED is set.
+func NewMusicBrainzClientFromEnv() *musicbrainz.Client {
+	if disabled, _ := strconv.ParseBool(os.Getenv("MUSICBRAINZ_DISABLED")); disabled {
+		return nil
+	}
+
+	client := musicbrainz.NewClient()
+	if baseURL := os.Getenv("MUSICBRAINZ_URL"); baseURL != "" {
+		client.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if userAgent := os.Getenv("MUSICBRAINZ_USER_AGENT"); userAgent != "" {
+		client.UserAgent = userAgent
+	}
+	client.Retry.OnRetry = func(attempt int, delay time.Duration, err error) {
+		log.Printf("MusicBrainz request failed (attempt %d), retrying in %s: %v", attempt, delay.Round(time.Millisecond), err)
+	}
+	return client
+}
+
+// Function to build a pipeline from the credentials in the environment.
+// Search results are cached in db unless it is nil.
+func NewPipelineFromEnv(db *store.DB) (*Pipeline, error) {
+	lastFmApiKey := os.Getenv("LASTFM_API_KEY")
+	lastFmUser := os.Getenv("LASTFM_USER")
+	if lastFmApiKey == "" || lastFmUser == "" {
+		return nil, &StepError{Step: stepConfig, Err: fmt.Errorf("%w: LASTFM_API_KEY and LASTFM_USER must be set in .env file", errNotConfigured)}
+	}
+
+	if os.Getenv("SPOTIFY_REFRESH_TOKEN") == "" {
+		return nil, &StepError{Step: stepConfig, Err: fmt.Errorf("%w: SPOTIFY_REFRESH_TOKEN not found in .env file. Please run with -auth flag to authenticate with Spotify", errNotConfigured)}
+	}
+
+	lastFmClient := lastfm.NewClient(lastFmApiKey)
+	lastFmClient.Retry.OnRetry = func(attempt int, delay time.Duration, err error) {
+		log.Printf("Last.fm request failed (attempt %d), retrying in %s: %v", attempt, delay.Round(time.Millisecond), err)
+	}
+
+	spotifyClient := NewSpotifyClient()
+	resolver := &Resolver{
+		Spotify:    spotifyClient,
+		Overrides:  db,
+		Threshold:  envFloat("MATCH_THRESHOLD", matching.DefaultThreshold),
+		Candidates: DefaultSearchCandidates,
+		Scorer:     matching.Scorer{Normalizer: normalize.New(normalize.DefaultRules())},
+	}
+	if db != nil {
+		resolver.Cache = NewMatchCacheFromEnv(db)
+	}
+	resolver.MusicBrainz = NewMusicBrainzClientFromEnv()
+
+	return &Pipeline{
+		LastFm:        lastFmClient,
+		LastFmUser:    lastFmUser,
+		Spotify:       spotifyClient,
+		Resolver:      resolver,
+		Store:         db,
+		SearchWorkers: DefaultSearchWorkers,
+
+		ReviewThreshold: envFloat("MATCH_REVIEW_THRESHOLD", DefaultReviewThreshold),
+	}, nil
+}
+
+func (p *Pipeline) printf(format string, args ...interface{}) {
+	if p.Progress != nil {
+		fmt.Fprintf(p.Progress, format, args...)
+	}
+}
+
+// scrobbles returns the scrobbles in [from, to), from Last.fm or from the local store
+func (p *Pipeline) scrobbles(ctx context.Context, offline bool, from time.Time, to time.Time) ([]lastfm.Track, error) {
+	if offline {
+		if p.Store == nil {
+			return nil, fmt.Errorf("%w: offline runs need the local database, see PLAYLISTINATOR_DB", errNotConfigured)
+		}
+		if _, ok, err := p.Store.LatestScrobble(); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("%w: no scrobbles stored yet, run the sync command first", errNotConfigured)
+		}
+		return p.Store.Scrobbles(from, to)
+	}
+
+	tracks, err := p.LastFm.GetRecentTracks(ctx, p.LastFmUser, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("fetching Last.fm tracks: %w", err)
+	}
+	return tracks, nil
+}
+
+// timeStep runs fn and records how long it took in timings. Errors are wrapped with the step name.
+func timeStep(timings *[]StepTiming, step string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	*timings = append(*timings, StepTiming{Step: step, Duration: elapsed, Millis: elapsed.Milliseconds()})
+	if err != nil {
+		return &StepError{Step: step, Err: err}
+	}
+	return nil
+}
+
+// Ranker returns the ranking strategy selected in the options
+func (o Options) Ranker() (ranking.Ranker, error) {
+	return ranking.New(o.Ranking, ranking.Params{
+		HalfLife: o.HalfLife,
+		Key:      normalize.New(o.Normalize).Key,
+	})
+}
+
+// Diversity returns the artist and album limits selected in the options
+func (o Options) Diversity() ranking.Diversity {
+	n := normalize.New(o.Normalize)
+	return ranking.Diversity{
+		MaxPerArtist: o.MaxPerArtist,
+		MaxPerAlbum:  o.MaxPerAlbum,
+		MinArtists:   o.MinArtists,
+		Artist:       func(t lastfm.Track) string { return n.Artist(t.Artist.Name) },
+		Album:        func(t lastfm.Track) string { return n.Album(t.Album.Name) },
+	}
+}
+
+// scrobbleRange is the range of scrobbles the ranking needs, which can start before the window
+func (o Options) scrobbleRange(now time.Time) (time.Time, time.Time) {
+	from, to := o.Range(now)
+	if ranker, err := o.Ranker(); err == nil {
+		from = ranking.Start(ranker, from, to)
+	}
+	return from, to
+}
+
+// scrobblesIn returns the finished scrobbles in [from, to)
+func scrobblesIn(tracks []lastfm.Track, from time.Time, to time.Time) []lastfm.Track {
+	var inRange []lastfm.Track
+	for _, track := range tracks {
+		playedAt, ok := track.ScrobbledAt()
+		if !ok || track.NowPlaying() || playedAt.Before(from) || !playedAt.Before(to) {
+			continue
+		}
+		inRange = append(inRange, track)
+	}
+	return inRange
+}
+
+// Run fetches scrobbles, ranks them and replaces the contents of the configured playlist
+func (p *Pipeline) Run(ctx context.Context, opts Options) (*Result, error) {
+	results, err := p.RunAll(ctx, opts.Offline, []Options{opts})
+	return results[0], err
+}
+
+// RunAll updates several playlists from a single scrobble fetch covering all of their
+// windows. A failing playlist doesn't stop the others, the errors are joined.
+func (p *Pipeline) RunAll(ctx context.Context, offline bool, playlists []Options) ([]*Result, error) {
+	results := make([]*Result, len(playlists))
+	for i, opts := range playlists {
+		results[i] = &Result{PlaylistName: opts.PlaylistName}
+	}
+	if len(playlists) == 0 {
+		return results, nil
+	}
+
+	// Fetch the union of all windows once, including what rankers need from before them
+	now := time.Now()
+	from, to := playlists[0].scrobbleRange(now)
+	for _, opts := range playlists[1:] {
+		f, t := opts.scrobbleRange(now)
+		if f.Before(from) {
+			from = f
+		}
+		if t.After(to) {
+			to = t
+		}
+	}
+	window := playlists[0].WindowLabel()
+	if len(playlists) > 1 {
+		window = Options{From: from, To: to}.WindowLabel()
+	}
+
+	// Step 1: fetch scrobbles
+	var tracks []lastfm.Track
+	if offline {
+		p.printf("Step 1: Reading stored scrobbles from %s...\n", window)
+	} else {
+		p.printf("Step 1: Fetching Last.fm tracks from %s...\n", window)
+	}
+	var fetchTimings []StepTiming
+	err := timeStep(&fetchTimings, stepFetch, func() error {
+		var err error
+		tracks, err = p.scrobbles(ctx, offline, from, to)
+		return err
+	})
+	for _, result := range results {
+		result.Timings = append(result.Timings, fetchTimings...)
+	}
+	if err != nil {
+		return results, err
+	}
+	p.printf("Found %d tracks from Last.fm\n", len(tracks))
+
+	var errs []error
+	for i, opts := range playlists {
+		if len(playlists) > 1 {
+			p.printf("\n=== Playlist %d of %d: %s ===\n", i+1, len(playlists), opts.PlaylistName)
+		}
+		f, t := opts.scrobbleRange(now)
+		if err := p.generate(ctx, opts, now, scrobblesIn(tracks, f, t), results[i]); err != nil {
+			if len(playlists) > 1 {
+				log.Printf("Could not update playlist '%s': %v", opts.PlaylistName, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 1 {
+		return results, errs[0]
+	}
+	return results, errors.Join(errs...)
+}
+
+// generate ranks the scrobbles of one playlist and replaces its contents. The
+// scrobbles may start before the window when the ranking looks further back.
+func (p *Pipeline) generate(ctx context.Context, opts Options, now time.Time, tracks []lastfm.Track, result *Result) error {
+	window := opts.WindowLabel()
+	from, to := opts.Range(now)
+	result.Scrobbles = len(scrobblesIn(tracks, from, to))
+	timed := func(step string, fn func() error) error {
+		return timeStep(&result.Timings, step, fn)
+	}
+
+	// Step 2: rank the tracks
+	var trackCounts []ranking.Ranked
+	p.printf("\nStep 2: Ranking tracks by %s...\n", opts.Ranking)
+	err := timed(stepCount, func() error {
+		ranker, err := opts.Ranker()
+		if err != nil {
+			return err
+		}
+		trackCounts = ranker.Rank(tracks, from, to)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	result.UniqueTracks = len(trackCounts)
+	p.printf("Found %d unique tracks\n", len(trackCounts))
+
+	// Drop tracks below the play threshold or matching an exclude rule before searching for them
+	filter, err := newTrackFilter(opts)
+	if err != nil {
+		return &StepError{Step: stepCount, Err: err}
+	}
+	trackCounts, result.Excluded = filter.Tracks(trackCounts)
+	if result.Excluded > 0 {
+		p.printf("Left out %d tracks by play count or exclude rules\n", result.Excluded)
+	}
+
+	// Create playlist description from the template, by default the top 10 tracks and their play counts
+	var description string
+	err = timed(stepDescribe, func() error {
+		var err error
+		description, err = renderDescription(opts, result.Scrobbles, trackCounts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Step 3: make sure the refresh token still works before touching the playlist
+	p.printf("\nStep 3: Getting Spotify access token...\n")
+	err = timed(stepToken, func() error {
+		_, err := p.Spotify.Tokens.Token(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	p.printf("Successfully obtained Spotify access token\n")
+
+	// Step 4: get or create the playlist and update its description
+	p.printf("\nStep 4: Getting or creating Spotify playlist...\n")
+	err = timed(stepPlaylist, func() error {
+		public := opts.Public
+		playlist, err := p.Spotify.GetOrCreatePlaylist(ctx, spotify.PlaylistDetails{
+			Name:        opts.PlaylistName,
+			Description: fmt.Sprintf("Top %d songs from %s", opts.Limit, window),
+			Public:      &public,
+		})
+		if err != nil {
+			return fmt.Errorf("getting playlist %q: %w", opts.PlaylistName, err)
+		}
+		result.PlaylistId = playlist.Id
+
+		err = p.Spotify.UpdatePlaylistDetails(ctx, playlist.Id, spotify.PlaylistDetails{
+			Description: description,
+			Public:      &public,
+		})
+		if err != nil {
+			return fmt.Errorf("updating playlist description: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.printf("Using playlist: %s (ID: %s)\n", opts.PlaylistName, result.PlaylistId)
+
+	// Step 5: search for the top songs on Spotify
+	var songUris []string
+	p.printf("\nStep 5: Searching for songs on Spotify...\n")
+	err = timed(stepSearch, func() error {
+		picked, resolved, err := p.selectTracks(ctx, opts, trackCounts, filter)
+		if err != nil {
+			return err
+		}
+		songUris = result.addResolutions(trackCounts, picked, resolved)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.printf("Found Spotify URIs for %d songs, %d through fallback searches\n", len(songUris), result.FallbackMatches)
+
+	// Keep unmatched and doubtful tracks for the unmatched command
+	if p.Store != nil {
+		report := NewRunReport(result, p.ReviewThreshold)
+		if err := p.Store.AddRunReport(report); err != nil {
+			log.Printf("Could not save run report: %v", err)
+		} else if len(report.Unmatched)+len(report.LowConfidence) > 0 {
+			p.printf("%d unmatched and %d low confidence tracks, see the unmatched command\n", len(report.Unmatched), len(report.LowConfidence))
+		}
+	}
+
+	// Step 6: replace the playlist contents
+	p.printf("\nStep 6: Adding songs to playlist...\n")
+	return timed(stepAdd, func() error {
+		if err := p.Spotify.ReplacePlaylistTracks(ctx, result.PlaylistId, songUris); err != nil {
+			return fmt.Errorf("adding songs to playlist: %w", err)
+		}
+		return nil
+	})
+}
+
+// addResolutions records the picked tracks in Matched and every other track that was
+// looked up in Unmatched, both in rank order, and returns the URIs of the picked tracks
+func (r *Result) addResolutions(trackCounts []ranking.Ranked, picked []int, resolved map[int]resolution) []string {
+	// Misses in rank order, whether or not a later track took their slot
+	for i := range trackCounts {
+		res, ok := resolved[i]
+		if !ok || res.Err == nil {
+			continue
+		}
+		track := trackCounts[i].Track
+		r.Unmatched = append(r.Unmatched, UnmatchedTrack{
+			Rank:   i + 1,
+			Artist: track.Artist.Name,
+			Name:   track.Name,
+			Album:  track.Album.Name,
+			Mbid:   track.Mbid,
+			Plays:  trackCounts[i].Count,
+			Score:  trackCounts[i].Score,
+			Reason: res.Err.Error(),
+
+			Candidates: res.Alternatives,
+			err:        res.Err,
+		})
+	}
+
+	var songUris []string
+	for _, i := range picked {
+		res := resolved[i]
+		track := trackCounts[i].Track
+		songUris = append(songUris, res.Uri)
+		r.Matched = append(r.Matched, ResolvedTrack{
+			Rank:   i + 1,
+			Artist: track.Artist.Name,
+			Name:   track.Name,
+			Album:  track.Album.Name,
+			Mbid:   track.Mbid,
+			Plays:  trackCounts[i].Count,
+			Score:  trackCounts[i].Score,
+			Uri:    res.Uri,
+
+			Confidence:   res.Match.Score,
+			Alternatives: res.Alternatives,
+			Method:       res.Method,
+		})
+		if res.Method != "" && res.Method != methodStrict && res.Method != methodIsrc {
+			r.FallbackMatches++
+		}
+	}
+	return songUris
+}
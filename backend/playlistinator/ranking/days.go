@@ -0,0 +1,68 @@
+package ranking
+
+import (
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// day returns the local calendar day of t as days since the Unix epoch
+func day(t time.Time) int64 {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+}
+
+// playDays returns the distinct days in times, unordered
+func playDays(times []time.Time) map[int64]bool {
+	days := make(map[int64]bool)
+	for _, t := range times {
+		days[day(t)] = true
+	}
+	return days
+}
+
+// ByDays ranks tracks by the number of different days they were played on, so
+// a song played once a day all month beats one looped twenty times in an evening
+type ByDays struct {
+	Key lastfm.KeyFunc
+}
+
+func (d ByDays) Rank(scrobbles []lastfm.Track, from time.Time, to time.Time) []Ranked {
+	counts, times := plays(scrobbles, d.Key)
+	ranked := make([]Ranked, len(counts))
+	for i, tc := range counts {
+		ranked[i] = Ranked{TrackCount: tc, Score: float64(len(playDays(times[i])))}
+	}
+	sortRanked(ranked)
+	return ranked
+}
+
+// ByStreak ranks tracks by the longest run of consecutive days they were played on
+type ByStreak struct {
+	Key lastfm.KeyFunc
+}
+
+func (s ByStreak) Rank(scrobbles []lastfm.Track, from time.Time, to time.Time) []Ranked {
+	counts, times := plays(scrobbles, s.Key)
+	ranked := make([]Ranked, len(counts))
+	for i, tc := range counts {
+		days := playDays(times[i])
+		longest := 0
+		for d := range days {
+			if days[d-1] {
+				// Only count runs from their first day
+				continue
+			}
+			streak := 1
+			for days[d+int64(streak)] {
+				streak++
+			}
+			if streak > longest {
+				longest = streak
+			}
+		}
+		ranked[i] = Ranked{TrackCount: tc, Score: float64(longest)}
+	}
+	sortRanked(ranked)
+	return ranked
+}
@@ -0,0 +1,36 @@
+package ranking
+
+import (
+	"math"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// DefaultHalfLife is the half-life of the decay strategy when none is configured
+const DefaultHalfLife = 7 * 24 * time.Hour
+
+// ByDecay weights each play by its age, so recent plays count more than old ones.
+// A play at the end of the window is worth 1, one HalfLife earlier it is worth 0.5.
+type ByDecay struct {
+	HalfLife time.Duration
+	Key      lastfm.KeyFunc
+}
+
+func (d ByDecay) Rank(scrobbles []lastfm.Track, from time.Time, to time.Time) []Ranked {
+	counts, times := plays(scrobbles, d.Key)
+	ranked := make([]Ranked, len(counts))
+	for i, tc := range counts {
+		score := 0.0
+		for _, playedAt := range times[i] {
+			age := to.Sub(playedAt)
+			if age < 0 {
+				age = 0
+			}
+			score += math.Exp2(-float64(age) / float64(d.HalfLife))
+		}
+		ranked[i] = Ranked{TrackCount: tc, Score: score}
+	}
+	sortRanked(ranked)
+	return ranked
+}
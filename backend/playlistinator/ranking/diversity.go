@@ -0,0 +1,124 @@
+package ranking
+
+import (
+	"sort"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// Diversity limits how much of a playlist one artist or album can take. Zero values
+// mean no limit.
+type Diversity struct {
+	MaxPerArtist int
+	MaxPerAlbum  int
+	// MinArtists swaps in tracks by new artists, best first, until the playlist has at least this many
+	MinArtists int
+
+	// Artist and Album identify artists and albums. Nil means the names as Last.fm sends them.
+	Artist func(lastfm.Track) string
+	Album  func(lastfm.Track) string
+}
+
+// Select picks up to limit tracks from ranked, best first, within the diversity limits.
+// Tracks skipped for a limit leave their slot to the next eligible track. The result
+// keeps the ranked order.
+func (d Diversity) Select(ranked []Ranked, limit int) []Ranked {
+	picked := d.Indexes(ranked, limit)
+	result := make([]Ranked, len(picked))
+	for i, index := range picked {
+		result[i] = ranked[index]
+	}
+	return result
+}
+
+// Indexes is Select returning the positions of the picked tracks in ranked, in increasing order
+func (d Diversity) Indexes(ranked []Ranked, limit int) []int {
+	artistOf := d.Artist
+	if artistOf == nil {
+		artistOf = func(t lastfm.Track) string { return t.Artist.Name }
+	}
+	albumOf := d.Album
+	if albumOf == nil {
+		albumOf = func(t lastfm.Track) string { return t.Album.Name }
+	}
+
+	artists := make([]string, len(ranked))
+	albums := make([]string, len(ranked))
+	for i, r := range ranked {
+		artists[i] = artistOf(r.Track)
+		albums[i] = albumOf(r.Track)
+	}
+
+	perArtist := make(map[string]int)
+	perAlbum := make(map[string]int)
+	distinct := 0
+	fits := func(i int) bool {
+		if d.MaxPerArtist > 0 && perArtist[artists[i]] >= d.MaxPerArtist {
+			return false
+		}
+		// Tracks without an album aren't on the same one
+		if d.MaxPerAlbum > 0 && albums[i] != "" && perAlbum[artists[i]+"\x1f"+albums[i]] >= d.MaxPerAlbum {
+			return false
+		}
+		return true
+	}
+	add := func(i int, n int) {
+		if perArtist[artists[i]] == 0 {
+			distinct++
+		}
+		perArtist[artists[i]] += n
+		if perArtist[artists[i]] == 0 {
+			distinct--
+		}
+		if albums[i] != "" {
+			perAlbum[artists[i]+"\x1f"+albums[i]] += n
+		}
+	}
+
+	// Greedy pass in rank order
+	var picked []int
+	selected := make([]bool, len(ranked))
+	for i := range ranked {
+		if len(picked) == limit {
+			break
+		}
+		if fits(i) {
+			picked = append(picked, i)
+			selected[i] = true
+			add(i, 1)
+		}
+	}
+
+	// Bring in new artists until there are enough, replacing the lowest ranked
+	// track of an artist that has more than one
+	for next := 0; d.MinArtists > 0 && distinct < d.MinArtists; next++ {
+		for next < len(ranked) && (selected[next] || perArtist[artists[next]] > 0 || !fits(next)) {
+			next++
+		}
+		if next == len(ranked) {
+			break
+		}
+
+		if len(picked) == limit {
+			drop := -1
+			for j, index := range picked {
+				if perArtist[artists[index]] > 1 && (drop < 0 || index > picked[drop]) {
+					drop = j
+				}
+			}
+			if drop < 0 {
+				break
+			}
+			selected[picked[drop]] = false
+			add(picked[drop], -1)
+			picked = append(picked[:drop], picked[drop+1:]...)
+		}
+
+		picked = append(picked, next)
+		selected[next] = true
+		add(next, 1)
+	}
+
+	sort.Ints(picked)
+	return picked
+}
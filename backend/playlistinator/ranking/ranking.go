@@ -0,0 +1,125 @@
+// Package ranking orders scrobbled tracks for a playlist
+package ranking
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// Names of the built-in strategies, as used in playlist definitions
+const (
+	Count   = "count"
+	Decayed = "decay"
+	Days    = "days"
+	Rising  = "rising"
+	Streak  = "streak"
+)
+
+// Ranked is a track with the score it was ranked by. Higher scores rank first.
+type Ranked struct {
+	lastfm.TrackCount
+	Score float64
+}
+
+// Ranker orders the tracks scrobbled in [from, to), best first. Scrobbles only
+// reach back before from for rankers that implement Lookback.
+type Ranker interface {
+	Rank(scrobbles []lastfm.Track, from time.Time, to time.Time) []Ranked
+}
+
+// Params configures the strategies that take parameters
+type Params struct {
+	// HalfLife is how long it takes a play to lose half its weight in the decay strategy
+	HalfLife time.Duration
+
+	// Key identifies tracks, so that different spellings can add up. Nil means lastfm.Track.Key.
+	Key lastfm.KeyFunc
+}
+
+// New returns the built-in strategy with the given name
+func New(name string, params Params) (Ranker, error) {
+	switch name {
+	case Count, "":
+		return ByCount{Key: params.Key}, nil
+	case Decayed:
+		halfLife := params.HalfLife
+		if halfLife == 0 {
+			halfLife = DefaultHalfLife
+		}
+		if halfLife < 0 {
+			return nil, fmt.Errorf("ranking: half-life must be positive, got %s", halfLife)
+		}
+		return ByDecay{HalfLife: halfLife, Key: params.Key}, nil
+	case Days:
+		return ByDays{Key: params.Key}, nil
+	case Rising:
+		return ByRising{Key: params.Key}, nil
+	case Streak:
+		return ByStreak{Key: params.Key}, nil
+	}
+	return nil, fmt.Errorf("ranking: unknown strategy %q, use one of %s", name, strings.Join(Names(), ", "))
+}
+
+// Names lists the built-in strategies
+func Names() []string {
+	return []string{Count, Decayed, Days, Rising, Streak}
+}
+
+// keyFunc returns key, or lastfm.Track.Key when it is nil
+func keyFunc(key lastfm.KeyFunc) lastfm.KeyFunc {
+	if key == nil {
+		return lastfm.Track.Key
+	}
+	return key
+}
+
+// plays groups the scrobbles by track. Tracks are in order of first appearance,
+// as in lastfm.CountTracks, and times[i] holds every play of counts[i].
+func plays(scrobbles []lastfm.Track, key lastfm.KeyFunc) (counts []lastfm.TrackCount, times [][]time.Time) {
+	key = keyFunc(key)
+	counts = lastfm.CountTracksBy(scrobbles, key)
+	index := make(map[lastfm.TrackKey]int, len(counts))
+	for i, tc := range counts {
+		index[key(tc.Track)] = i
+	}
+
+	times = make([][]time.Time, len(counts))
+	for _, track := range scrobbles {
+		playedAt, ok := track.ScrobbledAt()
+		if !ok || track.NowPlaying() {
+			continue
+		}
+		i := index[key(track)]
+		times[i] = append(times[i], playedAt)
+	}
+	return counts, times
+}
+
+// sortRanked orders by score, then play count. Ties keep their order of first appearance.
+func sortRanked(ranked []Ranked) {
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Count > ranked[j].Count
+	})
+}
+
+// ByCount ranks tracks by how often they were played
+type ByCount struct {
+	Key lastfm.KeyFunc
+}
+
+func (c ByCount) Rank(scrobbles []lastfm.Track, from time.Time, to time.Time) []Ranked {
+	counts := lastfm.CountTracksBy(scrobbles, c.Key)
+	ranked := make([]Ranked, len(counts))
+	for i, tc := range counts {
+		ranked[i] = Ranked{TrackCount: tc, Score: float64(tc.Count)}
+	}
+	sortRanked(ranked)
+	return ranked
+}
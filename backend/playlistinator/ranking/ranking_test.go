@@ -0,0 +1,181 @@
+package ranking
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+var end = time.Date(2024, 10, 31, 0, 0, 0, 0, time.UTC)
+
+func scrobble(name string, daysAgo float64) lastfm.Track {
+	playedAt := end.Add(-time.Duration(daysAgo * float64(24*time.Hour)))
+	return lastfm.Track{
+		Artist: lastfm.Artist{Name: "Artist"},
+		Name:   name,
+		Date:   &lastfm.Date{Uts: strconv.FormatInt(playedAt.Unix(), 10)},
+	}
+}
+
+func repeat(name string, n int, daysAgo float64) []lastfm.Track {
+	var tracks []lastfm.Track
+	for i := 0; i < n; i++ {
+		tracks = append(tracks, scrobble(name, daysAgo))
+	}
+	return tracks
+}
+
+func names(ranked []Ranked) []string {
+	var out []string
+	for _, r := range ranked {
+		out = append(out, r.Track.Name)
+	}
+	return out
+}
+
+func TestByCount(t *testing.T) {
+	scrobbles := append(repeat("old", 20, 29), repeat("new", 15, 1)...)
+	scrobbles = append(scrobbles, scrobble("once", 3))
+
+	ranked := ByCount{}.Rank(scrobbles, end.AddDate(0, 0, -30), end)
+	if got := names(ranked); len(got) != 3 || got[0] != "old" || got[1] != "new" || got[2] != "once" {
+		t.Fatalf("order = %v", got)
+	}
+	if ranked[0].Score != 20 || ranked[0].Count != 20 {
+		t.Errorf("top = %+v", ranked[0])
+	}
+}
+
+func TestByDecay(t *testing.T) {
+	// 20 plays a month ago lose to 15 plays yesterday with a one week half-life
+	scrobbles := append(repeat("old", 20, 29), repeat("new", 15, 1)...)
+
+	ranked := ByDecay{HalfLife: 7 * 24 * time.Hour}.Rank(scrobbles, end.AddDate(0, 0, -30), end)
+	if got := names(ranked); got[0] != "new" || got[1] != "old" {
+		t.Fatalf("order = %v", got)
+	}
+	if ranked[0].Count != 15 {
+		t.Errorf("Count = %d, want 15", ranked[0].Count)
+	}
+
+	// One play exactly one half-life before the end is worth half a play
+	ranked = ByDecay{HalfLife: 24 * time.Hour}.Rank([]lastfm.Track{scrobble("a", 1)}, end.AddDate(0, 0, -30), end)
+	if math.Abs(ranked[0].Score-0.5) > 1e-9 {
+		t.Errorf("Score = %v, want 0.5", ranked[0].Score)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if r, err := New(Decayed, Params{}); err != nil || r.(ByDecay).HalfLife != DefaultHalfLife {
+		t.Errorf("New(decay) = %v, %v", r, err)
+	}
+	if _, err := New("loudest", Params{}); err == nil {
+		t.Error("New accepted an unknown strategy")
+	}
+}
+
+func TestByDays(t *testing.T) {
+	// Looped on one evening vs once a day for a week
+	scrobbles := repeat("loop", 20, 2)
+	for d := 0; d < 7; d++ {
+		scrobbles = append(scrobbles, scrobble("daily", float64(d)+0.5))
+	}
+
+	ranked := ByDays{}.Rank(scrobbles, end.AddDate(0, 0, -30), end)
+	if got := names(ranked); got[0] != "daily" || ranked[0].Score != 7 || ranked[1].Score != 1 {
+		t.Fatalf("order = %v, scores %v and %v", got, ranked[0].Score, ranked[1].Score)
+	}
+}
+
+func TestByStreak(t *testing.T) {
+	var scrobbles []lastfm.Track
+	// "steady" is played three days in a row, "scattered" on four days with gaps
+	for _, d := range []float64{3.5, 4.5, 5.5} {
+		scrobbles = append(scrobbles, scrobble("steady", d))
+	}
+	for _, d := range []float64{1.5, 7.5, 10.5, 14.5} {
+		scrobbles = append(scrobbles, scrobble("scattered", d))
+	}
+
+	ranked := ByStreak{}.Rank(scrobbles, end.AddDate(0, 0, -30), end)
+	if got := names(ranked); got[0] != "steady" || ranked[0].Score != 3 || ranked[1].Score != 1 {
+		t.Fatalf("order = %v, scores %v and %v", got, ranked[0].Score, ranked[1].Score)
+	}
+}
+
+func TestByRising(t *testing.T) {
+	from := end.AddDate(0, 0, -7)
+	if since := Start(ByRising{}, from, end); !since.Equal(end.AddDate(0, 0, -14)) {
+		t.Fatalf("Start = %v", since)
+	}
+	if since := Start(ByCount{}, from, end); !since.Equal(from) {
+		t.Fatalf("Start = %v", since)
+	}
+
+	// "steady" was big last week too, "new" wasn't played before
+	scrobbles := append(repeat("steady", 10, 2), repeat("new", 6, 2)...)
+	scrobbles = append(scrobbles, repeat("steady", 9, 10)...)
+	scrobbles = append(scrobbles, repeat("gone", 5, 10)...)
+
+	ranked := ByRising{}.Rank(scrobbles, from, end)
+	if got := names(ranked); len(got) != 2 || got[0] != "new" || got[1] != "steady" {
+		t.Fatalf("order = %v", got)
+	}
+	if ranked[0].Score != 6 || ranked[1].Score != 1 || ranked[1].Count != 10 {
+		t.Errorf("ranked = %+v", ranked)
+	}
+}
+
+func rankedTracks(specs ...[3]string) []Ranked {
+	ranked := make([]Ranked, len(specs))
+	for i, spec := range specs {
+		ranked[i].Track = lastfm.Track{Artist: lastfm.Artist{Name: spec[0]}, Album: lastfm.Album{Name: spec[1]}, Name: spec[2]}
+		ranked[i].Score = float64(len(specs) - i)
+	}
+	return ranked
+}
+
+func TestDiversitySelect(t *testing.T) {
+	ranked := rankedTracks(
+		[3]string{"A", "A1", "a1"},
+		[3]string{"A", "A1", "a2"},
+		[3]string{"A", "A2", "a3"},
+		[3]string{"B", "B1", "b1"},
+		[3]string{"A", "A1", "a4"},
+		[3]string{"B", "B1", "b2"},
+		[3]string{"C", "C1", "c1"},
+		[3]string{"D", "D1", "d1"},
+	)
+
+	tests := []struct {
+		name      string
+		diversity Diversity
+		limit     int
+		want      []string
+	}{
+		{"no limits", Diversity{}, 4, []string{"a1", "a2", "a3", "b1"}},
+		{"per artist backfills", Diversity{MaxPerArtist: 2}, 4, []string{"a1", "a2", "b1", "b2"}},
+		{"per album", Diversity{MaxPerAlbum: 1}, 4, []string{"a1", "a3", "b1", "c1"}},
+		{"min artists replaces lowest", Diversity{MinArtists: 4}, 5, []string{"a1", "a2", "b1", "c1", "d1"}},
+		{"min artists with room", Diversity{MinArtists: 3}, 8, []string{"a1", "a2", "a3", "b1", "a4", "b2", "c1", "d1"}},
+		{"not enough artists", Diversity{MinArtists: 10}, 3, []string{"a1", "b1", "c1"}},
+		{"short list", Diversity{MaxPerArtist: 1}, 10, []string{"a1", "b1", "c1", "d1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(tt.diversity.Select(ranked, tt.limit))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
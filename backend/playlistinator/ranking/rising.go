@@ -0,0 +1,59 @@
+package ranking
+
+import (
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// Lookback is implemented by rankers that also need scrobbles from before the window.
+// Since returns the earliest scrobble time they need.
+type Lookback interface {
+	Since(from time.Time, to time.Time) time.Time
+}
+
+// Start returns the earliest scrobble time r needs to rank [from, to)
+func Start(r Ranker, from time.Time, to time.Time) time.Time {
+	if lookback, ok := r.(Lookback); ok {
+		if since := lookback.Since(from, to); since.Before(from) {
+			return since
+		}
+	}
+	return from
+}
+
+// ByRising ranks tracks by how many more plays they got in the window than in the
+// window of the same length just before it. Only tracks played in the window are ranked.
+type ByRising struct {
+	Key lastfm.KeyFunc
+}
+
+// Since asks for the previous window as well
+func (ByRising) Since(from time.Time, to time.Time) time.Time {
+	return from.Add(-to.Sub(from))
+}
+
+func (r ByRising) Rank(scrobbles []lastfm.Track, from time.Time, to time.Time) []Ranked {
+	key := keyFunc(r.Key)
+	var current []lastfm.Track
+	previous := make(map[lastfm.TrackKey]int)
+	for _, track := range scrobbles {
+		playedAt, ok := track.ScrobbledAt()
+		if !ok || track.NowPlaying() {
+			continue
+		}
+		if playedAt.Before(from) {
+			previous[key(track)]++
+		} else {
+			current = append(current, track)
+		}
+	}
+
+	counts := lastfm.CountTracksBy(current, key)
+	ranked := make([]Ranked, len(counts))
+	for i, tc := range counts {
+		ranked[i] = Ranked{TrackCount: tc, Score: float64(tc.Count - previous[key(tc.Track)])}
+	}
+	sortRanked(ranked)
+	return ranked
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/matching"
+	"github.com/tejaskoundinya/playlistinator/musicbrainz"
+	"github.com/tejaskoundinya/playlistinator/normalize"
+	"github.com/tejaskoundinya/playlistinator/ranking"
+	"github.com/tejaskoundinya/playlistinator/spotify"
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// Number of Spotify searches run in parallel by default
+const DefaultSearchWorkers = 8
+
+// Number of search results scored for each track by default
+const DefaultSearchCandidates = 5
+
+// Search methods, recorded with each match
+const (
+	methodOverride    = "override"
+	methodIsrc        = "isrc"
+	methodStrict      = "strict"
+	methodPlainTitle  = "plain-title"
+	methodNoFeaturing = "no-featuring"
+	methodFreeText    = "free-text"
+	methodAlbum       = "album"
+)
+
+// Number of results fetched for album searches, which list the whole album
+const albumSearchLimit = 20
+
+// searchAttempt is one query of the fallback chain
+type searchAttempt struct {
+	Method string
+	Query  string
+}
+
+// Function to build the queries tried for a track, strict first. When the strict
+// query misses, brackets and "feat." credits are dropped, then the field filters,
+// then the album is searched. Queries that are the same as an earlier one are skipped.
+func searchAttempts(track lastfm.Track) []searchAttempt {
+	plainTitle := normalize.StripBrackets(track.Name)
+	noFeatTitle := normalize.StripFeaturing(plainTitle)
+	noFeatArtist := normalize.StripFeaturing(track.Artist.Name)
+
+	attempts := []searchAttempt{
+		{methodStrict, fmt.Sprintf("track:%s artist:%s", track.Name, track.Artist.Name)},
+		{methodPlainTitle, fmt.Sprintf("track:%s artist:%s", plainTitle, track.Artist.Name)},
+		{methodNoFeaturing, fmt.Sprintf("track:%s artist:%s", noFeatTitle, noFeatArtist)},
+		{methodFreeText, fmt.Sprintf("%s %s", noFeatArtist, noFeatTitle)},
+	}
+	if track.Album.Name != "" {
+		attempts = append(attempts, searchAttempt{methodAlbum, fmt.Sprintf("album:%s artist:%s", track.Album.Name, noFeatArtist)})
+	}
+
+	seen := make(map[string]bool)
+	var unique []searchAttempt
+	for _, attempt := range attempts {
+		if !seen[attempt.Query] {
+			seen[attempt.Query] = true
+			unique = append(unique, attempt)
+		}
+	}
+	return unique
+}
+
+// Function to build the ISRC queries for a track that carries a recording MBID.
+// Lookups are cached with the matches since MusicBrainz only allows one per second.
+// MusicBrainz failures are logged and leave the text searches to find the track.
+func (r *Resolver) isrcAttempts(ctx context.Context, track lastfm.Track) ([]searchAttempt, error) {
+	if r.MusicBrainz == nil || track.Mbid == "" {
+		return nil, nil
+	}
+
+	isrcs, cached := r.cachedIsrcs(track.Mbid)
+	if !cached {
+		var err error
+		isrcs, err = r.MusicBrainz.Isrcs(ctx, track.Mbid)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// An MBID MusicBrainz doesn't know is cached as a recording without ISRCs
+		if err != nil && !errors.Is(err, musicbrainz.ErrNotFound) {
+			log.Printf("Could not look up ISRCs for %s - %s: %v", track.Artist.Name, track.Name, err)
+			return nil, nil
+		}
+		if r.Cache != nil {
+			if err := r.Cache.PutIsrcs(track.Mbid, isrcs); err != nil {
+				log.Printf("Could not write ISRC cache: %v", err)
+			}
+		}
+	}
+
+	var attempts []searchAttempt
+	for _, isrc := range isrcs {
+		attempts = append(attempts, searchAttempt{methodIsrc, "isrc:" + isrc})
+	}
+	return attempts, nil
+}
+
+// cachedIsrcs returns the ISRCs cached for an MBID and whether there were any
+func (r *Resolver) cachedIsrcs(mbid string) ([]string, bool) {
+	if r.Cache == nil {
+		return nil, false
+	}
+	isrcs, ok, err := r.Cache.Isrcs(mbid)
+	if err != nil {
+		log.Printf("Could not read ISRC cache: %v", err)
+		return nil, false
+	}
+	return isrcs, ok
+}
+
+// Resolver finds the Spotify URI for a Last.fm track
+type Resolver struct {
+	Spotify *spotify.Client
+	// MusicBrainz turns recording MBIDs into ISRCs, which are searched before any text. Nil disables ISRC matching.
+	MusicBrainz *musicbrainz.Client
+
+	// Cache remembers earlier searches, including misses. Nil disables caching.
+	Cache *store.MatchCache
+	// Overrides pins tracks to a URI or skips them, before the cache or a search. May be nil.
+	Overrides *store.DB
+
+	// Threshold is the lowest score accepted as a match. Zero means matching.DefaultThreshold.
+	Threshold float64
+	// Candidates is how many search results are scored. Zero means DefaultSearchCandidates.
+	Candidates int
+	// Scorer rates search results. Its Normalizer is shared by every search.
+	Scorer matching.Scorer
+}
+
+// Match is the Spotify track a Last.fm track resolved to
+type Match struct {
+	Uri string
+	// Score is the confidence of the match, from 0 to 1. Zero for matches cached before scoring.
+	Score float64
+	// Alternatives are the other candidates, best first. For tracks without a match
+	// these are the candidates that scored below the threshold.
+	Alternatives []matching.Candidate
+	// Method is the search that found the match, such as "strict" or "free-text"
+	Method string
+}
+
+func (r *Resolver) threshold() float64 {
+	if r.Threshold > 0 {
+		return r.Threshold
+	}
+	return matching.DefaultThreshold
+}
+
+// Function to resolve a track, checking overrides and the match cache before searching
+// Spotify. When nothing scores above the threshold the error is errNoMatch and the
+// returned match holds the candidates that were considered. Skipped tracks get errSkipped.
+// Cached matches under the threshold, including those cached before matches were
+// scored, are searched again.
+func (r *Resolver) Resolve(ctx context.Context, track lastfm.Track) (Match, error) {
+	if r.Overrides != nil {
+		override, err := r.Overrides.Override(track.Artist.Name, track.Name)
+		if err != nil {
+			log.Printf("Could not read overrides: %v", err)
+		} else if override != nil && override.Skip {
+			return Match{Method: methodOverride}, errSkipped
+		} else if override != nil {
+			return Match{Uri: override.Uri, Score: 1, Method: methodOverride}, nil
+		}
+	}
+
+	if r.Cache != nil {
+		cached, err := r.Cache.Get(track.Artist.Name, track.Name)
+		if err != nil {
+			log.Printf("Could not read match cache: %v", err)
+		} else if cached != nil && cached.NotFound && len(cached.Candidates) > 0 {
+			best := cached.Candidates[0]
+			return Match{Alternatives: cached.Candidates}, fmt.Errorf("%w: best candidate %s - %s scored %.2f when last searched", errNoMatch, best.Artist, best.Name, best.Score)
+		} else if cached != nil && cached.NotFound {
+			return Match{}, errNoMatch
+		} else if cached != nil && cached.Score >= r.threshold() {
+			return Match{Uri: cached.Uri, Score: cached.Score, Method: cached.Method}, nil
+		}
+	}
+
+	match, err := r.search(ctx, track)
+	if err != nil && !errors.Is(err, errNoMatch) {
+		// Don't cache API failures, only real answers
+		return Match{}, err
+	}
+
+	if r.Cache != nil {
+		var cacheErr error
+		if err != nil {
+			cacheErr = r.Cache.PutNotFound(track.Artist.Name, track.Name, match.Alternatives)
+		} else {
+			cacheErr = r.Cache.PutUri(track.Artist.Name, track.Name, match.Uri, match.Score, match.Method)
+		}
+		if cacheErr != nil {
+			log.Printf("Could not write match cache: %v", cacheErr)
+		}
+	}
+
+	return match, err
+}
+
+// search runs the fallback chain until a candidate scores above the threshold. Tracks
+// with an MBID are searched by ISRC first. The ISRC results are scored like any other,
+// so a wrong MBID on Last.fm doesn't pin a track to an unrelated recording.
+func (r *Resolver) search(ctx context.Context, track lastfm.Track) (Match, error) {
+	limit := r.Candidates
+	if limit <= 0 {
+		limit = DefaultSearchCandidates
+	}
+	target := matching.Target{
+		Artist: track.Artist.Name,
+		Title:  track.Name,
+		Album:  track.Album.Name,
+	}
+
+	// Every candidate seen so far, best first, for the report when nothing is good enough
+	var tried []matching.Candidate
+	seen := make(map[string]bool)
+
+	attempts, err := r.isrcAttempts(ctx, track)
+	if err != nil {
+		return Match{}, err
+	}
+	attempts = append(attempts, searchAttempts(track)...)
+
+	for _, attempt := range attempts {
+		// ISRC and strict searches come first, anything after them is a fallback
+		if attempt.Method != methodIsrc && attempt.Method != methodStrict {
+			log.Printf("Trying %s search for %s - %s: %s", attempt.Method, track.Artist.Name, track.Name, attempt.Query)
+		}
+
+		n := limit
+		if attempt.Method == methodAlbum {
+			n = max(limit, albumSearchLimit)
+		}
+		results, err := r.Spotify.SearchTracks(ctx, attempt.Query, n)
+		if err != nil {
+			return Match{}, err
+		}
+
+		for _, c := range r.Scorer.Rank(target, results) {
+			if !seen[c.Uri] {
+				seen[c.Uri] = true
+				tried = append(tried, c)
+			}
+		}
+		sort.SliceStable(tried, func(i, j int) bool { return tried[i].Score > tried[j].Score })
+
+		if len(tried) > 0 && tried[0].Score >= r.threshold() {
+			alternatives := tried[1:]
+			if len(alternatives) > limit {
+				alternatives = alternatives[:limit]
+			}
+			return Match{Uri: tried[0].Uri, Score: tried[0].Score, Alternatives: alternatives, Method: attempt.Method}, nil
+		}
+	}
+
+	if len(tried) == 0 {
+		return Match{}, errNoMatch
+	}
+	if len(tried) > limit {
+		tried = tried[:limit]
+	}
+	best := tried[0]
+	return Match{Alternatives: tried}, fmt.Errorf("%w: best candidate %s - %s scored %.2f, below %.2f", errNoMatch, best.Artist, best.Name, best.Score, r.threshold())
+}
+
+// resolution is the outcome of looking up one ranked track on Spotify
+type resolution struct {
+	Match
+	Err error
+}
+
+// isMiss reports whether err means a track has no Spotify URI, rather than that resolving failed
+func isMiss(err error) bool {
+	return errors.Is(err, errNoMatch) || errors.Is(err, errSkipped)
+}
+
+// Function to resolve the ranked tracks at indexes to Spotify URIs using a pool of
+// workers. Results are returned in the same order as indexes, and progress is
+// reported by rank out of all ranked tracks. Tracks that simply have no match get
+// errNoMatch in their resolution, or errSkipped when an override says so; any
+// other error stops all workers and is returned.
+func (p *Pipeline) resolveTracks(ctx context.Context, ranked []ranking.Ranked, indexes []int) ([]resolution, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := p.SearchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]resolution, len(indexes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				i := indexes[j]
+				track := ranked[i].Track
+				p.printf("Searching for %d/%d: %s - %s (%d plays)\n",
+					i+1, len(ranked), track.Artist.Name, track.Name, ranked[i].Count)
+
+				match, err := p.Resolver.Resolve(ctx, track)
+				if err != nil && !isMiss(err) {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("searching for %s - %s: %w", track.Artist.Name, track.Name, err)
+						cancel()
+					})
+					continue
+				}
+				if errors.Is(err, errNoMatch) {
+					log.Printf("Could not find Spotify URI for %s - %s: %v", track.Artist.Name, track.Name, err)
+				}
+				results[j] = resolution{Match: match, Err: err}
+			}
+		}()
+	}
+
+feed:
+	for j := range indexes {
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Function to pick the tracks of a playlist. Tracks are resolved in ranked order and
+// the diversity limits and opts.Limit apply to the ones that resolved, so a miss, a
+// skip, an excluded URI or a second track with the same URI, such as a remaster of
+// a song already picked, leaves its slot to the next candidate. Returns the picked
+// indexes into ranked, in order, and the resolution of every track looked up.
+func (p *Pipeline) selectTracks(ctx context.Context, opts Options, ranked []ranking.Ranked, filter *trackFilter) ([]int, map[int]resolution, error) {
+	diversity := opts.Diversity()
+	resolved := make(map[int]resolution)
+
+	for {
+		// Every track that hasn't failed yet is a candidate. Of the tracks that resolved
+		// to the same URI only the highest ranked one stays.
+		var eligible []int
+		uris := make(map[string]bool)
+		for i := range ranked {
+			res, ok := resolved[i]
+			if ok && res.Err == nil && uris[res.Uri] {
+				res.Err = fmt.Errorf("%w: %s", errDuplicate, res.Uri)
+				resolved[i] = res
+			}
+			if ok && res.Err == nil {
+				uris[res.Uri] = true
+			}
+			if !ok || res.Err == nil {
+				eligible = append(eligible, i)
+			}
+		}
+		candidates := make([]ranking.Ranked, len(eligible))
+		for j, i := range eligible {
+			candidates[j] = ranked[i]
+		}
+
+		var picked, pending []int
+		for _, j := range diversity.Indexes(candidates, opts.Limit) {
+			picked = append(picked, eligible[j])
+			if _, ok := resolved[eligible[j]]; !ok {
+				pending = append(pending, eligible[j])
+			}
+		}
+		if len(pending) == 0 {
+			return picked, resolved, nil
+		}
+
+		resolutions, err := p.resolveTracks(ctx, ranked, pending)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, res := range resolutions {
+			if res.Err == nil && filter.ExcludesUri(res.Uri) {
+				res.Err = fmt.Errorf("%w: %s", errExcluded, res.Uri)
+			}
+			resolved[pending[j]] = res
+		}
+	}
+}
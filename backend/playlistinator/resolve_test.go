@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/musicbrainz"
+	"github.com/tejaskoundinya/playlistinator/ranking"
+	"github.com/tejaskoundinya/playlistinator/spotify"
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// fakeSpotify is a stand-in for the Web API search that answers each query from
+// results and records the searches made
+type fakeSpotify struct {
+	results  map[string][]spotify.Track
+	searches atomic.Int32
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (f *fakeSpotify) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.searches.Add(1)
+	f.mu.Lock()
+	f.queries = append(f.queries, r.URL.Query().Get("q"))
+	f.mu.Unlock()
+	var body struct {
+		Tracks struct {
+			Items []spotify.Track `json:"items"`
+		} `json:"tracks"`
+	}
+	body.Tracks.Items = f.results[r.URL.Query().Get("q")]
+	json.NewEncoder(w).Encode(body)
+}
+
+// newTestResolver returns a resolver searching fake, with a match cache when db is set
+func newTestResolver(t *testing.T, fake *fakeSpotify, db *store.DB) *Resolver {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := spotify.NewClient(spotify.StaticToken("token"))
+	client.BaseURL = server.URL
+	client.Limiter = nil
+
+	r := &Resolver{Spotify: client}
+	if db != nil {
+		r.Cache = store.NewMatchCache(db)
+	}
+	return r
+}
+
+func openTestStore(t *testing.T) *store.DB {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testTrack(artist string, name string) lastfm.Track {
+	return lastfm.Track{Artist: lastfm.Artist{Name: artist}, Name: name}
+}
+
+func spotifyTrack(uri string, name string, artist string) spotify.Track {
+	return spotify.Track{Uri: uri, Name: name, Artists: []spotify.Artist{{Name: artist}}}
+}
+
+func TestResolveSearchesAgainForWeakCachedMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		cached     float64
+		wantUri    string
+		wantSearch bool
+	}{
+		{"cached before scoring", 0, "spotify:track:fresh", true},
+		{"below the threshold", 0.5, "spotify:track:fresh", true},
+		{"above the threshold", 0.9, "spotify:track:cached", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSpotify{results: map[string][]spotify.Track{
+				"track:Let Down artist:Radiohead": {spotifyTrack("spotify:track:fresh", "Let Down", "Radiohead")},
+			}}
+			r := newTestResolver(t, fake, openTestStore(t))
+			if err := r.Cache.PutUri("Radiohead", "Let Down", "spotify:track:cached", tt.cached, methodStrict); err != nil {
+				t.Fatal(err)
+			}
+
+			match, err := r.Resolve(context.Background(), testTrack("Radiohead", "Let Down"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match.Uri != tt.wantUri {
+				t.Errorf("uri = %s, want %s", match.Uri, tt.wantUri)
+			}
+			if searched := fake.searches.Load() > 0; searched != tt.wantSearch {
+				t.Errorf("searched = %v, want %v", searched, tt.wantSearch)
+			}
+		})
+	}
+}
+
+func TestResolveCachesCandidatesOfMisses(t *testing.T) {
+	fake := &fakeSpotify{results: map[string][]spotify.Track{
+		"track:Let Down artist:Radiohead": {spotifyTrack("spotify:track:cover", "Let Down (Karaoke Version)", "Karaoke All Stars")},
+	}}
+	r := newTestResolver(t, fake, openTestStore(t))
+	track := testTrack("Radiohead", "Let Down")
+
+	first, err := r.Resolve(context.Background(), track)
+	if !errors.Is(err, errNoMatch) || len(first.Alternatives) != 1 {
+		t.Fatalf("first resolve = %+v, %v", first, err)
+	}
+	searches := fake.searches.Load()
+
+	cached, err := r.Resolve(context.Background(), track)
+	if !errors.Is(err, errNoMatch) {
+		t.Fatalf("err = %v, want %v", err, errNoMatch)
+	}
+	if fake.searches.Load() != searches {
+		t.Error("searched again despite the cached miss")
+	}
+	if len(cached.Alternatives) != 1 || cached.Alternatives[0].Uri != "spotify:track:cover" {
+		t.Errorf("cached alternatives = %+v", cached.Alternatives)
+	}
+}
+
+// newTestMusicBrainz returns a client for a stand-in of the MusicBrainz API that
+// answers every recording lookup with isrcs
+func newTestMusicBrainz(t *testing.T, isrcs ...string) *musicbrainz.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(musicbrainz.Recording{Id: strings.TrimPrefix(r.URL.Path, "/recording/"), Isrcs: isrcs})
+	}))
+	t.Cleanup(server.Close)
+
+	client := musicbrainz.NewClient()
+	client.BaseURL = server.URL
+	client.Limiter = nil
+	return client
+}
+
+func TestResolveByIsrc(t *testing.T) {
+	tests := []struct {
+		name       string
+		isrcResult spotify.Track
+		wantUri    string
+		wantMethod string
+		searches   int32
+	}{
+		{
+			name:       "isrc hit",
+			isrcResult: spotifyTrack("spotify:track:isrc", "Let Down", "Radiohead"),
+			wantUri:    "spotify:track:isrc",
+			wantMethod: methodIsrc,
+			searches:   1,
+		},
+		{
+			// A wrong MBID on Last.fm leads to another recording, which scores too low
+			name:       "wrong mbid",
+			isrcResult: spotifyTrack("spotify:track:creep", "Creep", "Radiohead"),
+			wantUri:    "spotify:track:text",
+			wantMethod: methodStrict,
+			searches:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSpotify{results: map[string][]spotify.Track{
+				"isrc:GBAYE9700132":               {tt.isrcResult},
+				"track:Let Down artist:Radiohead": {spotifyTrack("spotify:track:text", "Let Down", "Radiohead")},
+			}}
+			db := openTestStore(t)
+			r := newTestResolver(t, fake, db)
+			r.MusicBrainz = newTestMusicBrainz(t, "GBAYE9700132")
+
+			track := testTrack("Radiohead", "Let Down")
+			track.Mbid = "b1a9c0e9-d987-4042-ae91-78d6a3267d69"
+			match, err := r.Resolve(context.Background(), track)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match.Uri != tt.wantUri || match.Method != tt.wantMethod {
+				t.Errorf("match = %s by %s, want %s by %s", match.Uri, match.Method, tt.wantUri, tt.wantMethod)
+			}
+			if got := fake.searches.Load(); got != tt.searches {
+				t.Errorf("searches = %d, want %d", got, tt.searches)
+			}
+
+			if isrcs, ok, err := r.Cache.Isrcs(track.Mbid); err != nil || !ok || len(isrcs) != 1 {
+				t.Errorf("cached isrcs = %v, %v, %v", isrcs, ok, err)
+			}
+		})
+	}
+}
+
+func TestResolveMatchesSpellingsOfOneTrack(t *testing.T) {
+	fake := &fakeSpotify{}
+	db := openTestStore(t)
+	r := newTestResolver(t, fake, db)
+	r.Overrides = db
+
+	// The override was saved for the spelling of one scrobble, the ranking picked another
+	if err := db.PutOverride(store.Override{Artist: "Queen", Track: "Don't Stop Me Now (Remastered 2011)", Uri: "spotify:track:pinned"}); err != nil {
+		t.Fatal(err)
+	}
+	match, err := r.Resolve(context.Background(), testTrack("queen", "Don't Stop Me Now"))
+	if err != nil || match.Uri != "spotify:track:pinned" || match.Method != methodOverride {
+		t.Errorf("override = %+v, %v", match, err)
+	}
+
+	if err := r.Cache.PutUri("Radiohead", "Let Down - Remastered", "spotify:track:cached", 0.9, methodStrict); err != nil {
+		t.Fatal(err)
+	}
+	match, err = r.Resolve(context.Background(), testTrack("Radiohead", "Let Down"))
+	if err != nil || match.Uri != "spotify:track:cached" {
+		t.Errorf("cached = %+v, %v", match, err)
+	}
+	if fake.searches.Load() != 0 {
+		t.Errorf("searched %d times", fake.searches.Load())
+	}
+}
+
+func TestResolveFallbacks(t *testing.T) {
+	tests := []struct {
+		name  string
+		track lastfm.Track
+		// answered is the only query the fake answers, with found
+		answered   string
+		found      spotify.Track
+		wantMethod string
+		// wantQueries are the searches made, duplicates of earlier queries left out
+		wantQueries []string
+	}{
+		{
+			name:       "plain title",
+			track:      testTrack("David Bowie", "Heroes (2017 Remaster)"),
+			answered:   "track:Heroes artist:David Bowie",
+			found:      spotifyTrack("spotify:track:found", "Heroes", "David Bowie"),
+			wantMethod: methodPlainTitle,
+			wantQueries: []string{
+				"track:Heroes (2017 Remaster) artist:David Bowie",
+				"track:Heroes artist:David Bowie",
+			},
+		},
+		{
+			name:       "no featuring",
+			track:      testTrack("Queen feat. David Bowie", "Under Pressure"),
+			answered:   "track:Under Pressure artist:Queen",
+			found:      spotifyTrack("spotify:track:found", "Under Pressure", "Queen"),
+			wantMethod: methodNoFeaturing,
+			wantQueries: []string{
+				"track:Under Pressure artist:Queen feat. David Bowie",
+				"track:Under Pressure artist:Queen",
+			},
+		},
+		{
+			name:       "free text",
+			track:      testTrack("David Bowie", "Heroes"),
+			answered:   "David Bowie Heroes",
+			found:      spotifyTrack("spotify:track:found", "Heroes", "David Bowie"),
+			wantMethod: methodFreeText,
+			wantQueries: []string{
+				"track:Heroes artist:David Bowie",
+				"David Bowie Heroes",
+			},
+		},
+		{
+			name: "album",
+			track: lastfm.Track{
+				Artist: lastfm.Artist{Name: "David Bowie"},
+				Name:   "Heroes",
+				Album:  lastfm.Album{Name: "Heroes"},
+			},
+			answered:   "album:Heroes artist:David Bowie",
+			found:      spotifyTrack("spotify:track:found", "Heroes", "David Bowie"),
+			wantMethod: methodAlbum,
+			wantQueries: []string{
+				"track:Heroes artist:David Bowie",
+				"David Bowie Heroes",
+				"album:Heroes artist:David Bowie",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSpotify{results: map[string][]spotify.Track{tt.answered: {tt.found}}}
+			r := newTestResolver(t, fake, nil)
+
+			match, err := r.Resolve(context.Background(), tt.track)
+			if err != nil || match.Uri != "spotify:track:found" || match.Method != tt.wantMethod {
+				t.Errorf("match = %+v, %v, want %s", match, err, tt.wantMethod)
+			}
+			if strings.Join(fake.queries, "\n") != strings.Join(tt.wantQueries, "\n") {
+				t.Errorf("queries = %q, want %q", fake.queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestFallbackMatchesCounted(t *testing.T) {
+	fake := &fakeSpotify{results: map[string][]spotify.Track{
+		"track:Heroes artist:David Bowie":   {spotifyTrack("spotify:track:heroes", "Heroes", "David Bowie")},
+		"track:Changes artist:David Bowie":  {spotifyTrack("spotify:track:changes", "Changes", "David Bowie")},
+		"track:Under Pressure artist:Queen": {spotifyTrack("spotify:track:pressure", "Under Pressure", "Queen")},
+		"David Bowie Starman":               {spotifyTrack("spotify:track:starman", "Starman", "David Bowie")},
+	}}
+	p := &Pipeline{Resolver: newTestResolver(t, fake, nil), SearchWorkers: 2}
+
+	ranked := []ranking.Ranked{
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Heroes"), Count: 5}},
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Changes (2015 Remaster)"), Count: 4}},
+		{TrackCount: lastfm.TrackCount{Track: testTrack("Queen feat. David Bowie", "Under Pressure"), Count: 3}},
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Starman"), Count: 2}},
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Life on Mars?"), Count: 1}},
+	}
+	opts := DefaultOptions()
+	filter, err := newTrackFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	picked, resolved, err := p.selectTracks(context.Background(), opts, ranked, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Result
+	uris := result.addResolutions(ranked, picked, resolved)
+	if len(uris) != 4 || len(result.Unmatched) != 1 || result.Unmatched[0].Name != "Life on Mars?" {
+		t.Fatalf("uris = %v, unmatched = %+v", uris, result.Unmatched)
+	}
+	// Changes, Under Pressure and Starman were only found by a fallback
+	if result.FallbackMatches != 3 {
+		t.Errorf("fallback matches = %d, want 3", result.FallbackMatches)
+	}
+	var methods []string
+	for _, m := range result.Matched {
+		methods = append(methods, m.Method)
+	}
+	want := []string{methodStrict, methodPlainTitle, methodNoFeaturing, methodFreeText}
+	if strings.Join(methods, ",") != strings.Join(want, ",") {
+		t.Errorf("methods = %v, want %v", methods, want)
+	}
+}
+
+func TestResolveProgressCountsRanks(t *testing.T) {
+	fake := &fakeSpotify{results: map[string][]spotify.Track{
+		"track:Changes artist:David Bowie": {spotifyTrack("spotify:track:changes", "Changes", "David Bowie")},
+		"track:Starman artist:David Bowie": {spotifyTrack("spotify:track:starman", "Starman", "David Bowie")},
+	}}
+	var progress strings.Builder
+	p := &Pipeline{Resolver: newTestResolver(t, fake, nil), SearchWorkers: 1, Progress: &progress}
+
+	ranked := []ranking.Ranked{
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Heroes"), Count: 3}},
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Changes"), Count: 2}},
+		{TrackCount: lastfm.TrackCount{Track: testTrack("David Bowie", "Starman"), Count: 1}},
+	}
+	opts := DefaultOptions()
+	opts.Limit = 2
+	filter, err := newTrackFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Heroes misses, so Starman is searched in a second batch
+	if _, _, err := p.selectTracks(context.Background(), opts, ranked, filter); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Searching for 1/3: David Bowie - Heroes (3 plays)\n" +
+		"Searching for 2/3: David Bowie - Changes (2 plays)\n" +
+		"Searching for 3/3: David Bowie - Starman (1 plays)\n"
+	if progress.String() != want {
+		t.Errorf("progress =\n%s\nwant\n%s", progress.String(), want)
+	}
+}
@@ -0,0 +1,157 @@
+// Package spotify is a small client for the parts of the Spotify Web API
+// used to look up tracks and maintain playlists.
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tejaskoundinya/playlistinator/internal/ratelimit"
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+)
+
+const (
+	DefaultBaseURL = "https://api.spotify.com/v1"
+
+	// Spotify doesn't publish a fixed limit, this keeps bursts of searches well clear of 429s
+	DefaultRequestsPerSecond = 10
+)
+
+// All clients in a process share one limiter by default, since Spotify limits per app
+var defaultLimiter = ratelimit.New(DefaultRequestsPerSecond)
+
+// Client talks to the Spotify Web API. The zero value is not usable, use NewClient.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Tokens     TokenSource
+
+	// Retry controls how 429s, 5xx responses and network errors are retried
+	Retry retry.Policy
+
+	// Limiter spaces out requests, including those made by concurrent callers. A nil limiter does not limit.
+	Limiter *ratelimit.Limiter
+}
+
+// NewClient returns a client for the public Web API authenticated by tokens
+func NewClient(tokens TokenSource) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: http.DefaultClient,
+		Tokens:     tokens,
+		Retry:      retry.DefaultPolicy,
+		Limiter:    defaultLimiter,
+	}
+}
+
+// do sends a request to path, which is either relative to BaseURL or an absolute
+// URL such as a "next" paging link, and decodes the JSON response into out if set.
+// Transient failures are retried according to c.Retry, and a rejected access token
+// is refreshed once if the token source supports it. POST requests are only retried
+// when Spotify cannot have acted on them, see doOnce.
+func (c *Client) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var jsonData []byte
+	if in != nil {
+		var err error
+		if jsonData, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	send := func(ctx context.Context) error {
+		return c.doOnce(ctx, method, path, jsonData, out)
+	}
+
+	err := c.Retry.Do(ctx, send)
+	if errors.Is(err, ErrUnauthorized) {
+		if tokens, ok := c.Tokens.(interface{ Invalidate() }); ok {
+			tokens.Invalidate()
+			err = c.Retry.Do(ctx, send)
+		}
+	}
+	return err
+}
+
+// doOnce makes a single attempt at a request. Resending a POST that reached Spotify
+// could add the same tracks twice, so for POST only 429s and connections that were
+// never made are left retryable.
+func (c *Client) doOnce(ctx context.Context, method string, path string, jsonData []byte, out interface{}) error {
+	endpoint := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		endpoint = c.BaseURL + path
+	}
+
+	var body io.Reader
+	if jsonData != nil {
+		body = bytes.NewReader(jsonData)
+	}
+
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := c.Tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if jsonData != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		err = fmt.Errorf("spotify: %s %s: %w", method, path, err)
+		if neverSent(err) {
+			return err
+		}
+		return mayHaveReached(method, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		if errors.As(err, new(ErrRateLimited)) {
+			return err
+		}
+		return mayHaveReached(method, err)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return mayHaveReached(method, fmt.Errorf("spotify: decoding %s %s: %w", method, path, err))
+	}
+	return nil
+}
+
+// idempotent reports whether sending a request twice has the same effect as sending it once
+func idempotent(method string) bool {
+	return method != "POST"
+}
+
+// neverSent reports whether a transport error happened before the request left, such as a failed dial
+func neverSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// mayHaveReached stops a failed non-idempotent request that Spotify may have acted on from being retried
+func mayHaveReached(method string, err error) error {
+	if idempotent(method) {
+		return err
+	}
+	return notRetryable{err}
+}
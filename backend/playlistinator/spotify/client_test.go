@@ -0,0 +1,211 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/internal/retry"
+)
+
+// newTestClient returns a client for a local stand-in of the Web API
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewClient(StaticToken("token"))
+	c.BaseURL = server.URL
+	c.Limiter = nil
+	c.Retry = retry.Policy{MaxAttempts: 3}
+	return c
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		call     func(c *Client) error
+		attempts int
+	}{
+		{
+			name:   "add tracks after 502",
+			status: http.StatusBadGateway,
+			call: func(c *Client) error {
+				return c.AddPlaylistTracks(context.Background(), "p", []string{"spotify:track:1"})
+			},
+			attempts: 1,
+		},
+		{
+			name:   "add tracks after 429",
+			status: http.StatusTooManyRequests,
+			call: func(c *Client) error {
+				return c.AddPlaylistTracks(context.Background(), "p", []string{"spotify:track:1"})
+			},
+			attempts: 3,
+		},
+		{
+			name:   "remove tracks after 502",
+			status: http.StatusBadGateway,
+			call: func(c *Client) error {
+				return c.RemovePlaylistTracks(context.Background(), "p", []string{"spotify:track:1"})
+			},
+			attempts: 3,
+		},
+		{
+			name:   "search after 502",
+			status: http.StatusBadGateway,
+			call: func(c *Client) error {
+				_, err := c.SearchTracks(context.Background(), "q", 1)
+				return err
+			},
+			attempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			})
+
+			if err := tt.call(c); err == nil {
+				t.Fatal("no error")
+			}
+			if got := int(attempts.Load()); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestPostRetriedWhenNeverSent(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	var attempts int
+	c := NewClient(StaticToken("token"))
+	c.BaseURL = server.URL
+	c.Limiter = nil
+	c.Retry = retry.Policy{MaxAttempts: 3, OnRetry: func(int, time.Duration, error) { attempts++ }}
+
+	err := c.AddPlaylistTracks(context.Background(), "p", []string{"spotify:track:1"})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if attempts != 2 {
+		t.Errorf("retries = %d, want 2: %v", attempts, err)
+	}
+}
+
+func TestPostResentAfterUnauthorized(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	tokens := &countingTokens{}
+	c.Tokens = tokens
+
+	if err := c.AddPlaylistTracks(context.Background(), "p", []string{"spotify:track:1"}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Load() != 2 || tokens.invalidated != 1 {
+		t.Errorf("attempts = %d, invalidated = %d", attempts.Load(), tokens.invalidated)
+	}
+}
+
+// countingTokens is a token source that counts how often the token was rejected
+type countingTokens struct {
+	invalidated int
+}
+
+func (t *countingTokens) Token(ctx context.Context) (string, error) {
+	return "token", nil
+}
+
+func (t *countingTokens) Invalidate() {
+	t.invalidated++
+}
+
+// fakePlaylist is a stand-in for the tracks endpoint of one playlist. Requests with a
+// method in fail get a 502 without changing the playlist.
+type fakePlaylist struct {
+	mu     sync.Mutex
+	tracks []string
+	fail   map[string]bool
+}
+
+func (f *fakePlaylist) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail[r.Method] {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	var body struct {
+		Uris []string `json:"uris"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	switch r.Method {
+	case "PUT":
+		f.tracks = body.Uris
+	case "POST":
+		f.tracks = append(f.tracks, body.Uris...)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Write([]byte(`{"snapshot_id": "s"}`))
+}
+
+func uris(n int) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		result = append(result, fmt.Sprintf("spotify:track:%d", i))
+	}
+	return result
+}
+
+func TestReplacePlaylistTracks(t *testing.T) {
+	tests := []struct {
+		name       string
+		uris       []string
+		fail       string
+		wantErr    bool
+		wantTracks int
+	}{
+		{"one batch", uris(3), "", false, 3},
+		{"several batches", uris(250), "", false, 250},
+		{"empty playlist", nil, "", false, 0},
+		{"failed replace keeps the old tracks", uris(3), "PUT", true, 2},
+		// Only the appended batches are lost, the playlist is never emptied
+		{"failed append", uris(150), "POST", true, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakePlaylist{tracks: []string{"spotify:track:old1", "spotify:track:old2"}, fail: map[string]bool{tt.fail: true}}
+			c := newTestClient(t, fake.ServeHTTP)
+
+			err := c.ReplacePlaylistTracks(context.Background(), "p", tt.uris)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(fake.tracks) != tt.wantTracks {
+				t.Errorf("playlist has %d tracks, want %d", len(fake.tracks), tt.wantTracks)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is returned when the access token is missing, expired or revoked
+	ErrUnauthorized = errors.New("spotify: unauthorized")
+
+	// ErrNotFound is returned when the requested resource does not exist
+	ErrNotFound = errors.New("spotify: not found")
+)
+
+// ErrRateLimited is returned when Spotify answers with HTTP 429
+type ErrRateLimited struct {
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	return fmt.Sprintf("spotify: rate limited, retry after %s", e.RetryAfter)
+}
+
+func (e ErrRateLimited) Retryable() bool {
+	return true
+}
+
+func (e ErrRateLimited) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// APIError is any other non-2xx response from the Web API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("spotify: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("spotify: %d %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the error is a transient server side failure
+func (e *APIError) Retryable() bool {
+	return e.StatusCode >= 500
+}
+
+// Unwrap lets callers match on ErrUnauthorized and ErrNotFound with errors.Is
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+	return nil
+}
+
+// notRetryable wraps an error that must not be retried even though it looks transient
+type notRetryable struct {
+	err error
+}
+
+func (e notRetryable) Error() string {
+	return e.err.Error()
+}
+
+func (e notRetryable) Unwrap() error {
+	return e.err
+}
+
+func (e notRetryable) Retryable() bool {
+	return false
+}
+
+// checkResponse turns a non-2xx response into one of the typed errors above
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	// Web API errors look like {"error": {"status": 401, "message": "..."}}
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	json.Unmarshal(data, &body)
+
+	return &APIError{StatusCode: resp.StatusCode, Message: body.Error.Message}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
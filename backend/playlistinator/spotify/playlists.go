@@ -0,0 +1,178 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// Spotify accepts at most 100 items per playlist modification request
+const maxItemsPerRequest = 100
+
+// CurrentUser returns the profile of the user the token belongs to
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, "GET", "/me", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindPlaylist returns the current user's playlist with the given name, or ErrNotFound
+func (c *Client) FindPlaylist(ctx context.Context, name string) (*Playlist, error) {
+	next := "/me/playlists?limit=50"
+	for next != "" {
+		var page struct {
+			Items []Playlist `json:"items"`
+			Next  string     `json:"next"`
+		}
+		if err := c.do(ctx, "GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, playlist := range page.Items {
+			if playlist.Name == name {
+				return &playlist, nil
+			}
+		}
+		next = page.Next
+	}
+
+	return nil, ErrNotFound
+}
+
+// CreatePlaylist creates a playlist owned by the given user
+func (c *Client) CreatePlaylist(ctx context.Context, userId string, details PlaylistDetails) (*Playlist, error) {
+	if details.Public == nil {
+		public := false
+		details.Public = &public
+	}
+
+	var playlist Playlist
+	path := "/users/" + url.PathEscape(userId) + "/playlists"
+	if err := c.do(ctx, "POST", path, details, &playlist); err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// GetOrCreatePlaylist finds the current user's playlist named details.Name, creating it if it does not exist
+func (c *Client) GetOrCreatePlaylist(ctx context.Context, details PlaylistDetails) (*Playlist, error) {
+	playlist, err := c.FindPlaylist(ctx, details.Name)
+	if err == nil {
+		return playlist, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	user, err := c.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.CreatePlaylist(ctx, user.Id, details)
+}
+
+// UpdatePlaylistDetails changes the name, description or visibility of a playlist
+func (c *Client) UpdatePlaylistDetails(ctx context.Context, playlistId string, details PlaylistDetails) error {
+	return c.do(ctx, "PUT", "/playlists/"+url.PathEscape(playlistId), details, nil)
+}
+
+// PlaylistTrackUris returns the URIs of every track in a playlist
+func (c *Client) PlaylistTrackUris(ctx context.Context, playlistId string) ([]string, error) {
+	var uris []string
+
+	next := "/playlists/" + url.PathEscape(playlistId) + "/tracks?fields=items(track(uri)),next&limit=100"
+	for next != "" {
+		var page struct {
+			Items []struct {
+				Track struct {
+					Uri string `json:"uri"`
+				} `json:"track"`
+			} `json:"items"`
+			Next string `json:"next"`
+		}
+		if err := c.do(ctx, "GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			uris = append(uris, item.Track.Uri)
+		}
+		next = page.Next
+	}
+
+	return uris, nil
+}
+
+// RemovePlaylistTracks removes every occurrence of the given tracks from a playlist
+func (c *Client) RemovePlaylistTracks(ctx context.Context, playlistId string, uris []string) error {
+	path := "/playlists/" + url.PathEscape(playlistId) + "/tracks"
+
+	for _, batch := range batches(uris) {
+		type trackRef struct {
+			Uri string `json:"uri"`
+		}
+		var body struct {
+			Tracks []trackRef `json:"tracks"`
+		}
+		for _, uri := range batch {
+			body.Tracks = append(body.Tracks, trackRef{Uri: uri})
+		}
+
+		if err := c.do(ctx, "DELETE", path, body, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddPlaylistTracks appends tracks to a playlist
+func (c *Client) AddPlaylistTracks(ctx context.Context, playlistId string, uris []string) error {
+	path := "/playlists/" + url.PathEscape(playlistId) + "/tracks"
+
+	for _, batch := range batches(uris) {
+		body := struct {
+			Uris []string `json:"uris"`
+		}{
+			Uris: batch,
+		}
+		if err := c.do(ctx, "POST", path, body, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplacePlaylistTracks sets the tracks of a playlist to uris, in order. The first
+// batch replaces the contents with a PUT, which is safe to retry, so a failure leaves
+// the old tracks in place rather than an empty playlist. Further batches are appended.
+func (c *Client) ReplacePlaylistTracks(ctx context.Context, playlistId string, uris []string) error {
+	path := "/playlists/" + url.PathEscape(playlistId) + "/tracks"
+
+	first := uris[:min(len(uris), maxItemsPerRequest)]
+	body := struct {
+		Uris []string `json:"uris"`
+	}{
+		Uris: append([]string{}, first...),
+	}
+	if err := c.do(ctx, "PUT", path, body, nil); err != nil {
+		return err
+	}
+
+	return c.AddPlaylistTracks(ctx, playlistId, uris[len(first):])
+}
+
+func batches(uris []string) [][]string {
+	var result [][]string
+	for i := 0; i < len(uris); i += maxItemsPerRequest {
+		end := i + maxItemsPerRequest
+		if end > len(uris) {
+			end = len(uris)
+		}
+		result = append(result, uris[i:end])
+	}
+	return result
+}
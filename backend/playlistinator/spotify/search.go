@@ -0,0 +1,26 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// SearchTracks runs a track search and returns up to limit results
+func (c *Client) SearchTracks(ctx context.Context, query string, limit int) ([]Track, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", "track")
+	params.Set("limit", strconv.Itoa(limit))
+
+	var result struct {
+		Tracks struct {
+			Items []Track `json:"items"`
+		} `json:"tracks"`
+	}
+	if err := c.do(ctx, "GET", "/search?"+params.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Tracks.Items, nil
+}
@@ -0,0 +1,159 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTokenURL = "https://accounts.spotify.com/api/token"
+
+	// Access tokens are refreshed this long before Spotify says they expire
+	DefaultExpiryDelta = time.Minute
+)
+
+// TokenSource supplies the bearer token sent with every Web API request
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same access token
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// RefreshTokenSource exchanges a refresh token for an access token on the accounts service.
+// The access token is cached until shortly before it expires and is safe to share between goroutines.
+type RefreshTokenSource struct {
+	TokenURL     string
+	HTTPClient   *http.Client
+	ClientId     string
+	ClientSecret string
+	ExpiryDelta  time.Duration
+
+	// OnRefreshTokenRotated is called with the new refresh token when Spotify issues one,
+	// so it can be persisted. It runs while the token source is locked and should be quick.
+	OnRefreshTokenRotated func(refreshToken string)
+
+	mu           sync.Mutex
+	refreshToken string
+	accessToken  string
+	expiry       time.Time
+}
+
+// NewRefreshTokenSource returns a token source backed by the Spotify accounts service
+func NewRefreshTokenSource(clientId, clientSecret, refreshToken string) *RefreshTokenSource {
+	return &RefreshTokenSource{
+		TokenURL:     DefaultTokenURL,
+		HTTPClient:   http.DefaultClient,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		ExpiryDelta:  DefaultExpiryDelta,
+		refreshToken: refreshToken,
+	}
+}
+
+// Token returns the cached access token, refreshing it first if it is about to expire.
+// Concurrent callers wait for a single refresh instead of each calling the accounts service.
+func (s *RefreshTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && time.Now().Add(s.ExpiryDelta).Before(s.expiry) {
+		return s.accessToken, nil
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return "", err
+	}
+	return s.accessToken, nil
+}
+
+// Invalidate drops the cached access token, for example after the Web API rejected it
+func (s *RefreshTokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessToken = ""
+}
+
+// refresh exchanges the refresh token for a new access token. The caller must hold s.mu.
+func (s *RefreshTokenSource) refresh(ctx context.Context) error {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", s.refreshToken)
+	data.Set("client_id", s.ClientId)
+	data.Set("client_secret", s.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("spotify: requesting access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkTokenResponse(resp); err != nil {
+		return err
+	}
+
+	var tokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return fmt.Errorf("spotify: decoding access token: %w", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return fmt.Errorf("spotify: no access token in accounts response")
+	}
+
+	s.accessToken = tokenResponse.AccessToken
+	s.expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+
+	if tokenResponse.RefreshToken != "" && tokenResponse.RefreshToken != s.refreshToken {
+		s.refreshToken = tokenResponse.RefreshToken
+		if s.OnRefreshTokenRotated != nil {
+			s.OnRefreshTokenRotated(tokenResponse.RefreshToken)
+		}
+	}
+
+	return nil
+}
+
+// checkTokenResponse maps accounts service errors, which use the OAuth error format
+func checkTokenResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	var body struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	json.Unmarshal(data, &body)
+
+	// A revoked or unknown refresh token comes back as 400 invalid_grant
+	if body.Error == "invalid_grant" || body.Error == "invalid_client" || resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w: %s %s", ErrUnauthorized, body.Error, body.ErrorDescription)
+	}
+
+	return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(body.Error + " " + body.ErrorDescription)}
+}
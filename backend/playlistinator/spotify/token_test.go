@@ -0,0 +1,100 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer is a stand-in for the accounts service that counts refreshes. Every
+// refresh returns access token "access-<n>" and rotates the refresh token to "rotated".
+func newTokenServer(t *testing.T, expiresIn int, refreshes *atomic.Int32, gotRefreshTokens chan<- string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if gotRefreshTokens != nil {
+			gotRefreshTokens <- r.PostForm.Get("refresh_token")
+		}
+		n := refreshes.Add(1)
+		// Slow enough for concurrent callers to pile up behind the first refresh
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token": "access-%d", "expires_in": %d, "refresh_token": "rotated"}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRefreshTokenSourceSharesOneRefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	server := newTokenServer(t, 3600, &refreshes, nil)
+
+	var rotated []string
+	source := NewRefreshTokenSource("id", "secret", "original")
+	source.TokenURL = server.URL
+	source.OnRefreshTokenRotated = func(refreshToken string) {
+		rotated = append(rotated, refreshToken)
+	}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := source.Token(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			tokens[i] = token
+		}()
+	}
+	wg.Wait()
+
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("refreshes = %d, want 1", got)
+	}
+	for i, token := range tokens {
+		if token != "access-1" {
+			t.Errorf("token %d = %q", i, token)
+		}
+	}
+	if fmt.Sprint(rotated) != "[rotated]" {
+		t.Errorf("rotated = %v", rotated)
+	}
+}
+
+func TestRefreshTokenSourceUsesRotatedToken(t *testing.T) {
+	var refreshes atomic.Int32
+	gotRefreshTokens := make(chan string, 3)
+	// Tokens that expire within ExpiryDelta are refreshed on every call
+	server := newTokenServer(t, 30, &refreshes, gotRefreshTokens)
+
+	rotations := 0
+	source := NewRefreshTokenSource("id", "secret", "original")
+	source.TokenURL = server.URL
+	source.OnRefreshTokenRotated = func(string) { rotations++ }
+
+	for i := 1; i <= 2; i++ {
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("access-%d", i); token != want {
+			t.Errorf("token = %q, want %q", token, want)
+		}
+	}
+
+	if first, second := <-gotRefreshTokens, <-gotRefreshTokens; first != "original" || second != "rotated" {
+		t.Errorf("refresh tokens sent = %q, %q", first, second)
+	}
+	// The second refresh returned the same refresh token, which is not a rotation
+	if rotations != 1 {
+		t.Errorf("rotations = %d, want 1", rotations)
+	}
+}
@@ -0,0 +1,45 @@
+package spotify
+
+type User struct {
+	Id          string `json:"id"`
+	DisplayName string `json:"display_name"`
+}
+
+type Artist struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Album struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ExternalIds struct {
+	Isrc string `json:"isrc"`
+}
+
+type Track struct {
+	Uri         string      `json:"uri"`
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	Artists     []Artist    `json:"artists"`
+	Album       Album       `json:"album"`
+	DurationMs  int         `json:"duration_ms"`
+	ExternalIds ExternalIds `json:"external_ids"`
+}
+
+type Playlist struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	Uri         string `json:"uri"`
+}
+
+// PlaylistDetails are the editable attributes of a playlist. Empty fields are left unchanged on update.
+type PlaylistDetails struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Public      *bool  `json:"public,omitempty"`
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var isrcsBucket = []byte("isrcs")
+
+// IsrcLookup is a cached MusicBrainz lookup of the ISRCs of a recording
+type IsrcLookup struct {
+	Mbid     string    `json:"mbid"`
+	Isrcs    []string  `json:"isrcs,omitempty"`
+	CachedAt time.Time `json:"cachedAt"`
+}
+
+// Isrcs returns the cached ISRCs of the recording with the MBID. The bool is false when
+// the recording was not looked up within the TTL, a lookup that found none returns true.
+func (c *MatchCache) Isrcs(mbid string) ([]string, bool, error) {
+	var lookup *IsrcLookup
+	err := c.DB.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(isrcsBucket).Get([]byte(mbid))
+		if data == nil {
+			return nil
+		}
+		lookup = &IsrcLookup{}
+		return json.Unmarshal(data, lookup)
+	})
+	if err != nil || lookup == nil {
+		return nil, false, err
+	}
+	if c.IsrcsExpired(*lookup, time.Now()) {
+		return nil, false, nil
+	}
+	return lookup.Isrcs, true, nil
+}
+
+// IsrcsExpired reports whether a lookup is older than its TTL. Lookups that found no
+// ISRCs expire after NotFoundTTL, like misses, since MusicBrainz gets edited.
+func (c *MatchCache) IsrcsExpired(lookup IsrcLookup, now time.Time) bool {
+	ttl := c.TTL
+	if len(lookup.Isrcs) == 0 {
+		ttl = c.NotFoundTTL
+	}
+	return now.Sub(lookup.CachedAt) > ttl
+}
+
+// AllIsrcs returns every cached ISRC lookup, including expired ones, ordered by MBID
+func (c *MatchCache) AllIsrcs() ([]IsrcLookup, error) {
+	var lookups []IsrcLookup
+	err := c.DB.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(isrcsBucket).ForEach(func(k, v []byte) error {
+			var lookup IsrcLookup
+			if err := json.Unmarshal(v, &lookup); err != nil {
+				return err
+			}
+			lookups = append(lookups, lookup)
+			return nil
+		})
+	})
+	return lookups, err
+}
+
+// PurgeIsrcs deletes cached ISRC lookups and returns how many were removed.
+// With expiredOnly set, lookups still within their TTL are kept.
+func (c *MatchCache) PurgeIsrcs(expiredOnly bool) (int, error) {
+	now := time.Now()
+	return c.purge(isrcsBucket, func(v []byte) bool {
+		var lookup IsrcLookup
+		return expiredOnly && json.Unmarshal(v, &lookup) == nil && !c.IsrcsExpired(lookup, now)
+	})
+}
+
+// PutIsrcs caches the ISRCs of a recording, which may be none
+func (c *MatchCache) PutIsrcs(mbid string, isrcs []string) error {
+	data, err := json.Marshal(IsrcLookup{Mbid: mbid, Isrcs: isrcs, CachedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return c.DB.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(isrcsBucket).Put([]byte(mbid), data)
+	})
+}
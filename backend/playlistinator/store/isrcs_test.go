@@ -0,0 +1,62 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsrcsExpiry(t *testing.T) {
+	c := &MatchCache{TTL: 90 * 24 * time.Hour, NotFoundTTL: 7 * 24 * time.Hour}
+	now := time.Now()
+	tests := []struct {
+		name    string
+		lookup  IsrcLookup
+		expired bool
+	}{
+		{"found, recent", IsrcLookup{Isrcs: []string{"GB1"}, CachedAt: now.Add(-30 * 24 * time.Hour)}, false},
+		{"found, old", IsrcLookup{Isrcs: []string{"GB1"}, CachedAt: now.Add(-100 * 24 * time.Hour)}, true},
+		{"none, recent", IsrcLookup{CachedAt: now.Add(-24 * time.Hour)}, false},
+		{"none, past the not found TTL", IsrcLookup{CachedAt: now.Add(-30 * 24 * time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if got := c.IsrcsExpired(tt.lookup, now); got != tt.expired {
+			t.Errorf("%s: expired = %v, want %v", tt.name, got, tt.expired)
+		}
+	}
+}
+
+func TestIsrcsCache(t *testing.T) {
+	c := NewMatchCache(openTestDB(t))
+	if _, ok, err := c.Isrcs("unknown"); err != nil || ok {
+		t.Fatalf("unknown mbid = %v, %v", ok, err)
+	}
+
+	if err := c.PutIsrcs("found", []string{"GB1", "GB2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutIsrcs("none", nil); err != nil {
+		t.Fatal(err)
+	}
+	if isrcs, ok, err := c.Isrcs("found"); err != nil || !ok || len(isrcs) != 2 {
+		t.Errorf("found = %v, %v, %v", isrcs, ok, err)
+	}
+	if isrcs, ok, err := c.Isrcs("none"); err != nil || !ok || len(isrcs) != 0 {
+		t.Errorf("none = %v, %v, %v", isrcs, ok, err)
+	}
+
+	// Empty lookups use the shorter TTL
+	c.NotFoundTTL = -time.Second
+	if _, ok, _ := c.Isrcs("none"); ok {
+		t.Error("empty lookup still cached past NotFoundTTL")
+	}
+	if removed, err := c.PurgeIsrcs(true); err != nil || removed != 1 {
+		t.Errorf("purged %d expired lookups, %v", removed, err)
+	}
+	lookups, err := c.AllIsrcs()
+	if err != nil || len(lookups) != 1 || lookups[0].Mbid != "found" {
+		t.Errorf("after purge = %+v, %v", lookups, err)
+	}
+	if removed, _ := c.PurgeIsrcs(false); removed != 1 {
+		t.Errorf("purged %d lookups, want 1", removed)
+	}
+}
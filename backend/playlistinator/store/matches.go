@@ -0,0 +1,166 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/matching"
+	"github.com/tejaskoundinya/playlistinator/normalize"
+)
+
+var matchesBucket = []byte("matches")
+
+// Default lifetimes of cached search results. Misses expire sooner because
+// tracks get added to Spotify and search improves.
+const (
+	DefaultMatchTTL    = 90 * 24 * time.Hour
+	DefaultNotFoundTTL = 7 * 24 * time.Hour
+)
+
+// Match is a cached Spotify search result for a Last.fm artist and track
+type Match struct {
+	Key      string    `json:"key"`
+	Artist   string    `json:"artist"`
+	Track    string    `json:"track"`
+	Uri      string    `json:"uri,omitempty"`
+	Score    float64   `json:"score,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	NotFound bool      `json:"notFound,omitempty"`
+	CachedAt time.Time `json:"cachedAt"`
+
+	// Candidates of a miss are the search results that scored below the threshold
+	Candidates []matching.Candidate `json:"candidates,omitempty"`
+}
+
+// Normalizer for keys, the default rules that group scrobbles before ranking
+var keyNormalizer = normalize.New(normalize.DefaultRules())
+
+// MatchKey returns the cache and override key for an artist and track. It is the
+// identity normalize.Key gives the track, so "Song (Remastered 2011)" and "Song"
+// share one key just like they share one entry in the ranking.
+func MatchKey(artist string, track string) string {
+	key := keyNormalizer.Key(lastfm.Track{Artist: lastfm.Artist{Name: artist}, Name: track})
+	return key.Artist + "\x1f" + key.Name
+}
+
+// MatchCache stores search results in the matches bucket and expires them after a TTL
+type MatchCache struct {
+	DB          *DB
+	TTL         time.Duration
+	NotFoundTTL time.Duration
+}
+
+// NewMatchCache returns a cache with the default TTLs
+func NewMatchCache(db *DB) *MatchCache {
+	return &MatchCache{DB: db, TTL: DefaultMatchTTL, NotFoundTTL: DefaultNotFoundTTL}
+}
+
+// Expired reports whether m is older than the TTL that applies to it
+func (c *MatchCache) Expired(m Match, now time.Time) bool {
+	ttl := c.TTL
+	if m.NotFound {
+		ttl = c.NotFoundTTL
+	}
+	return now.Sub(m.CachedAt) > ttl
+}
+
+// Get returns the cached result for artist and track, or nil if there is none or it has expired
+func (c *MatchCache) Get(artist string, track string) (*Match, error) {
+	var match *Match
+	err := c.DB.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(matchesBucket).Get([]byte(MatchKey(artist, track)))
+		if data == nil {
+			return nil
+		}
+		match = &Match{}
+		return json.Unmarshal(data, match)
+	})
+	if err != nil || match == nil {
+		return nil, err
+	}
+	if c.Expired(*match, time.Now()) {
+		return nil, nil
+	}
+	return match, nil
+}
+
+// PutUri caches a successful search with the confidence of the match and how it was found
+func (c *MatchCache) PutUri(artist string, track string, uri string, score float64, method string) error {
+	return c.put(Match{Artist: artist, Track: track, Uri: uri, Score: score, Method: method})
+}
+
+// PutNotFound caches a search that found nothing good enough, with the candidates it rejected
+func (c *MatchCache) PutNotFound(artist string, track string, candidates []matching.Candidate) error {
+	return c.put(Match{Artist: artist, Track: track, NotFound: true, Candidates: candidates})
+}
+
+func (c *MatchCache) put(m Match) error {
+	m.Key = MatchKey(m.Artist, m.Track)
+	m.CachedAt = time.Now()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.DB.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(matchesBucket).Put([]byte(m.Key), data)
+	})
+}
+
+// All returns every cached entry, including expired ones, ordered by key
+func (c *MatchCache) All() ([]Match, error) {
+	var matches []Match
+	err := c.DB.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(matchesBucket).ForEach(func(k, v []byte) error {
+			var m Match
+			if err := json.Unmarshal(v, &m); err != nil {
+				return err
+			}
+			matches = append(matches, m)
+			return nil
+		})
+	})
+	return matches, err
+}
+
+// Purge deletes cached entries and returns how many were removed.
+// With expiredOnly set, entries still within their TTL are kept.
+func (c *MatchCache) Purge(expiredOnly bool) (int, error) {
+	now := time.Now()
+	return c.purge(matchesBucket, func(v []byte) bool {
+		var m Match
+		return expiredOnly && json.Unmarshal(v, &m) == nil && !c.Expired(m, now)
+	})
+}
+
+// purge deletes the entries of a bucket that keep doesn't hold on to
+func (c *MatchCache) purge(name []byte, keep func(v []byte) bool) (int, error) {
+	removed := 0
+	err := c.DB.bolt.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(name)
+
+		// Collect keys first, deleting while iterating with a cursor skips entries
+		var keys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			if !keep(v) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	return removed, err
+}
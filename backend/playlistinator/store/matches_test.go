@@ -0,0 +1,83 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/matching"
+)
+
+func TestMatchExpiry(t *testing.T) {
+	c := &MatchCache{TTL: 90 * 24 * time.Hour, NotFoundTTL: 7 * 24 * time.Hour}
+	now := time.Now()
+	tests := []struct {
+		name    string
+		match   Match
+		expired bool
+	}{
+		{"found, recent", Match{Uri: "spotify:track:1", CachedAt: now.Add(-30 * 24 * time.Hour)}, false},
+		{"found, old", Match{Uri: "spotify:track:1", CachedAt: now.Add(-100 * 24 * time.Hour)}, true},
+		{"not found, recent", Match{NotFound: true, CachedAt: now.Add(-24 * time.Hour)}, false},
+		{"not found, past the not found TTL", Match{NotFound: true, CachedAt: now.Add(-30 * 24 * time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if got := c.Expired(tt.match, now); got != tt.expired {
+			t.Errorf("%s: expired = %v, want %v", tt.name, got, tt.expired)
+		}
+	}
+}
+
+func TestMatchCache(t *testing.T) {
+	c := NewMatchCache(openTestDB(t))
+	if m, err := c.Get("Queen", "Bohemian Rhapsody"); err != nil || m != nil {
+		t.Fatalf("empty cache = %+v, %v", m, err)
+	}
+
+	if err := c.PutUri("Queen", "Bohemian Rhapsody", "spotify:track:1", 0.95, "strict"); err != nil {
+		t.Fatal(err)
+	}
+	rejected := []matching.Candidate{{Uri: "spotify:track:2", Artist: "Tribute Band", Name: "Radio Ga Ga", Score: 0.4}}
+	if err := c.PutNotFound("Queen", "Radio Ga Ga", rejected); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := c.Get("queen", "bohemian rhapsody")
+	if err != nil || m == nil || m.Uri != "spotify:track:1" || m.Score != 0.95 || m.Method != "strict" || m.CachedAt.IsZero() {
+		t.Fatalf("found = %+v, %v", m, err)
+	}
+	m, err = c.Get("Queen", "Radio Ga Ga")
+	if err != nil || m == nil || !m.NotFound || len(m.Candidates) != 1 || m.Candidates[0].Uri != "spotify:track:2" {
+		t.Fatalf("not found = %+v, %v", m, err)
+	}
+
+	// A negative TTL expires everything just written
+	c.NotFoundTTL = -time.Second
+	if m, _ := c.Get("Queen", "Radio Ga Ga"); m != nil {
+		t.Errorf("miss still cached past NotFoundTTL: %+v", m)
+	}
+	if m, _ := c.Get("Queen", "Bohemian Rhapsody"); m == nil {
+		t.Error("hit expired with the not found TTL")
+	}
+
+	// Export includes expired entries, ordered by key
+	all, err := c.All()
+	if err != nil || len(all) != 2 || all[0].Track != "Bohemian Rhapsody" || all[1].Track != "Radio Ga Ga" {
+		t.Fatalf("all = %+v, %v", all, err)
+	}
+
+	removed, err := c.Purge(true)
+	if err != nil || removed != 1 {
+		t.Errorf("purge expired = %d, %v", removed, err)
+	}
+	if all, _ := c.All(); len(all) != 1 || all[0].Uri != "spotify:track:1" {
+		t.Errorf("after purging expired = %+v", all)
+	}
+
+	removed, err = c.Purge(false)
+	if err != nil || removed != 1 {
+		t.Errorf("purge = %d, %v", removed, err)
+	}
+	if all, _ := c.All(); len(all) != 0 {
+		t.Errorf("after purge = %+v", all)
+	}
+}
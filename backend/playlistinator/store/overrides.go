@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var overridesBucket = []byte("overrides")
+
+// Override pins a Last.fm artist and track to a Spotify URI, or skips it
+type Override struct {
+	Key       string    `json:"key"`
+	Artist    string    `json:"artist"`
+	Track     string    `json:"track"`
+	Uri       string    `json:"uri,omitempty"`
+	Skip      bool      `json:"skip,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Override returns the override for artist and track, or nil if there is none.
+// Keys are matched the same way as the match cache, see MatchKey.
+func (d *DB) Override(artist string, track string) (*Override, error) {
+	var override *Override
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(overridesBucket).Get([]byte(MatchKey(artist, track)))
+		if data == nil {
+			return nil
+		}
+		override = &Override{}
+		return json.Unmarshal(data, override)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return override, nil
+}
+
+// PutOverride adds or replaces an override
+func (d *DB) PutOverride(o Override) error {
+	o.Key = MatchKey(o.Artist, o.Track)
+	o.CreatedAt = time.Now()
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(overridesBucket).Put([]byte(o.Key), data)
+	})
+}
+
+// DeleteOverride removes the override for artist and track and reports whether there was one
+func (d *DB) DeleteOverride(artist string, track string) (bool, error) {
+	found := false
+	err := d.bolt.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(overridesBucket)
+		key := []byte(MatchKey(artist, track))
+		found = bucket.Get(key) != nil
+		return bucket.Delete(key)
+	})
+	return found, err
+}
+
+// Overrides returns every override ordered by key
+func (d *DB) Overrides() ([]Override, error) {
+	var overrides []Override
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(overridesBucket).ForEach(func(k, v []byte) error {
+			var o Override
+			if err := json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+			overrides = append(overrides, o)
+			return nil
+		})
+	})
+	return overrides, err
+}
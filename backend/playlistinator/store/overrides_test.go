@@ -0,0 +1,48 @@
+package store
+
+import "testing"
+
+func TestOverrides(t *testing.T) {
+	db := openTestDB(t)
+
+	if override, err := db.Override("Queen", "Song"); err != nil || override != nil {
+		t.Fatalf("override before any were saved = %+v, %v", override, err)
+	}
+
+	if err := db.PutOverride(Override{Artist: "Queen", Track: "Song (Remastered 2011)", Uri: "spotify:track:1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutOverride(Override{Artist: "ABBA", Track: "Waterloo", Skip: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both spellings of a track find its override
+	for _, track := range []string{"Song (Remastered 2011)", "Song", "song - remastered"} {
+		override, err := db.Override("queen", track)
+		if err != nil || override == nil || override.Uri != "spotify:track:1" || override.CreatedAt.IsZero() {
+			t.Errorf("Override(queen, %q) = %+v, %v", track, override, err)
+		}
+	}
+
+	// Saving again replaces rather than adds
+	if err := db.PutOverride(Override{Artist: "Queen", Track: "Song", Uri: "spotify:track:2"}); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := db.Overrides()
+	if err != nil || len(overrides) != 2 {
+		t.Fatalf("overrides = %+v, %v", overrides, err)
+	}
+	if overrides[0].Track != "Waterloo" || !overrides[0].Skip || overrides[1].Uri != "spotify:track:2" {
+		t.Errorf("overrides = %+v", overrides)
+	}
+
+	if found, err := db.DeleteOverride("Queen", "Song (Remastered)"); err != nil || !found {
+		t.Errorf("delete = %v, %v", found, err)
+	}
+	if found, err := db.DeleteOverride("Queen", "Song"); err != nil || found {
+		t.Errorf("second delete = %v, %v", found, err)
+	}
+	if override, _ := db.Override("Queen", "Song"); override != nil {
+		t.Errorf("override after delete = %+v", override)
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tejaskoundinya/playlistinator/matching"
+)
+
+var runsBucket = []byte("runs")
+
+// MaxRunReports is how many run reports are kept, older ones are deleted
+const MaxRunReports = 100
+
+// ReviewTrack is a ranked track that needs a look, either because nothing matched
+// or because the match was not confident
+type ReviewTrack struct {
+	Rank       int                  `json:"rank"`
+	Artist     string               `json:"artist"`
+	Name       string               `json:"name"`
+	Album      string               `json:"album,omitempty"`
+	Plays      int                  `json:"plays"`
+	Reason     string               `json:"reason,omitempty"`
+	Uri        string               `json:"uri,omitempty"`
+	Confidence float64              `json:"confidence,omitempty"`
+	Method     string               `json:"method,omitempty"`
+	Candidates []matching.Candidate `json:"candidates,omitempty"`
+}
+
+// RunReport lists the tracks of one playlist run that need review
+type RunReport struct {
+	PlaylistName  string        `json:"playlistName"`
+	PlaylistId    string        `json:"playlistId"`
+	At            time.Time     `json:"at"`
+	Matched       int           `json:"matched"`
+	Unmatched     []ReviewTrack `json:"unmatched"`
+	LowConfidence []ReviewTrack `json:"lowConfidence"`
+}
+
+// AddRunReport stores a report and deletes the oldest ones beyond MaxRunReports
+func (d *DB) AddRunReport(report RunReport) error {
+	key := make([]byte, 8, 8+len(report.PlaylistName))
+	binary.BigEndian.PutUint64(key, uint64(report.At.UnixNano()))
+	key = append(key, report.PlaylistName...)
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(runsBucket)
+		if err := bucket.Put(key, data); err != nil {
+			return err
+		}
+
+		// Keys sort by time, so the oldest come first
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		if len(keys) <= MaxRunReports {
+			return nil
+		}
+		for _, k := range keys[:len(keys)-MaxRunReports] {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RunReports returns the stored reports, newest first
+func (d *DB) RunReports() ([]RunReport, error) {
+	var reports []RunReport
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(runsBucket).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var report RunReport
+			if err := json.Unmarshal(v, &report); err != nil {
+				return err
+			}
+			reports = append(reports, report)
+		}
+		return nil
+	})
+	return reports, err
+}
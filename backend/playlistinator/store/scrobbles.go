@@ -0,0 +1,113 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+var scrobblesBucket = []byte("scrobbles")
+
+// scrobbleKey orders scrobbles by time. The artist and track are appended so that
+// storing the same scrobble twice is a no-op while different tracks in the same
+// second are kept apart.
+func scrobbleKey(uts int64, track lastfm.Track) []byte {
+	key := make([]byte, 8, 8+len(track.Artist.Name)+len(track.Name)+1)
+	binary.BigEndian.PutUint64(key, uint64(uts))
+	key = append(key, track.Artist.Name...)
+	key = append(key, 0x1f)
+	key = append(key, track.Name...)
+	return key
+}
+
+// AddScrobbles stores tracks and returns how many were new. Tracks without a
+// scrobble time, like the one currently playing, are skipped.
+func (d *DB) AddScrobbles(tracks []lastfm.Track) (int, error) {
+	added := 0
+	err := d.bolt.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(scrobblesBucket)
+		for _, track := range tracks {
+			playedAt, ok := track.ScrobbledAt()
+			if !ok || track.NowPlaying() {
+				continue
+			}
+			key := scrobbleKey(playedAt.Unix(), track)
+			if bucket.Get(key) != nil {
+				continue
+			}
+
+			// Artwork URLs are large and not needed for ranking
+			track.Image = nil
+			data, err := json.Marshal(track)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key, data); err != nil {
+				return err
+			}
+			added++
+		}
+		return nil
+	})
+	return added, err
+}
+
+// LatestScrobble returns the time of the newest stored scrobble, or false if there are none
+func (d *DB) LatestScrobble() (time.Time, bool, error) {
+	var latest time.Time
+	found := false
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(scrobblesBucket).Cursor().Last()
+		if k != nil {
+			latest = time.Unix(int64(binary.BigEndian.Uint64(k[:8])), 0)
+			found = true
+		}
+		return nil
+	})
+	return latest, found, err
+}
+
+// CountScrobbles returns the number of stored scrobbles
+func (d *DB) CountScrobbles() (int, error) {
+	count := 0
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(scrobblesBucket).Stats().KeyN
+		return nil
+	})
+	return count, err
+}
+
+// Scrobbles returns the stored scrobbles in [from, to), newest first like the Last.fm API
+func (d *DB) Scrobbles(from time.Time, to time.Time) ([]lastfm.Track, error) {
+	var tracks []lastfm.Track
+
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, uint64(from.Unix()))
+	end := make([]byte, 8)
+	binary.BigEndian.PutUint64(end, uint64(to.Unix()))
+
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(scrobblesBucket).Cursor()
+		for k, v := cursor.Seek(start); k != nil && bytes.Compare(k[:8], end) < 0; k, v = cursor.Next() {
+			var track lastfm.Track
+			if err := json.Unmarshal(v, &track); err != nil {
+				return err
+			}
+			tracks = append(tracks, track)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(tracks)-1; i < j; i, j = i+1, j-1 {
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	}
+	return tracks, nil
+}
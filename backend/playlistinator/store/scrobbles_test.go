@@ -0,0 +1,104 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+)
+
+// scrobble returns a track scrobbled at uts, or the track playing now when uts is 0
+func scrobble(uts int64, artist string, name string) lastfm.Track {
+	track := lastfm.Track{Artist: lastfm.Artist{Name: artist}, Name: name}
+	if uts == 0 {
+		track.Attr = &lastfm.TrackAttr{NowPlaying: "true"}
+	} else {
+		track.Date = &lastfm.Date{Uts: strconv.FormatInt(uts, 10)}
+	}
+	return track
+}
+
+func TestAddScrobbles(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, found, err := db.LatestScrobble(); err != nil || found {
+		t.Fatalf("latest of an empty store = %v, %v", found, err)
+	}
+
+	added, err := db.AddScrobbles([]lastfm.Track{
+		scrobble(0, "Queen", "Playing Now"),
+		scrobble(300, "Queen", "Bohemian Rhapsody"),
+		scrobble(200, "Queen", "Radio Ga Ga"),
+		// Same second, different track
+		scrobble(200, "ABBA", "Waterloo"),
+		scrobble(100, "Queen", "Bohemian Rhapsody"),
+	})
+	if err != nil || added != 4 {
+		t.Fatalf("added = %d, %v", added, err)
+	}
+
+	// Pages overlap when syncing, only new scrobbles are added
+	added, err = db.AddScrobbles([]lastfm.Track{
+		scrobble(0, "ABBA", "Playing Now"),
+		scrobble(400, "ABBA", "Waterloo"),
+		scrobble(300, "Queen", "Bohemian Rhapsody"),
+		scrobble(200, "ABBA", "Waterloo"),
+	})
+	if err != nil || added != 1 {
+		t.Fatalf("added again = %d, %v", added, err)
+	}
+
+	if count, err := db.CountScrobbles(); err != nil || count != 5 {
+		t.Errorf("count = %d, %v", count, err)
+	}
+	if latest, found, err := db.LatestScrobble(); err != nil || !found || latest.Unix() != 400 {
+		t.Errorf("latest = %v, %v, %v", latest, found, err)
+	}
+}
+
+func TestScrobbles(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.AddScrobbles([]lastfm.Track{
+		scrobble(100, "Queen", "Bohemian Rhapsody"),
+		scrobble(200, "ABBA", "Waterloo"),
+		scrobble(200, "Queen", "Radio Ga Ga"),
+		scrobble(300, "Queen", "Bohemian Rhapsody"),
+		scrobble(400, "ABBA", "Dancing Queen"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int64
+		want     []string
+	}{
+		{"everything", 0, 1000, []string{"Dancing Queen", "Bohemian Rhapsody", "Radio Ga Ga", "Waterloo", "Bohemian Rhapsody"}},
+		{"from is inclusive", 200, 300, []string{"Radio Ga Ga", "Waterloo"}},
+		{"to is exclusive", 101, 400, []string{"Bohemian Rhapsody", "Radio Ga Ga", "Waterloo"}},
+		{"empty range", 201, 300, nil},
+		{"after the last", 401, 1000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks, err := db.Scrobbles(time.Unix(tt.from, 0), time.Unix(tt.to, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, track := range tracks {
+				names = append(names, track.Name)
+			}
+			if len(names) != len(tt.want) {
+				t.Fatalf("got %v, want %v", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", names, tt.want)
+				}
+			}
+		})
+	}
+}
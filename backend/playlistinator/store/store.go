@@ -0,0 +1,113 @@
+// Package store keeps playlistinator's local state in a single bbolt database file.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultPath is used when PLAYLISTINATOR_DB is not set
+const DefaultPath = "playlistinator.db"
+
+// DB is an open store. bbolt locks the file, so only one process can have it open at a time.
+type DB struct {
+	bolt *bolt.DB
+}
+
+var buckets = [][]byte{
+	matchesBucket,
+	scrobblesBucket,
+	overridesBucket,
+	runsBucket,
+	isrcsBucket,
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("store: opening %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: creating buckets: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{matchesBucket, overridesBucket} {
+			if err := rekey(tx.Bucket(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: updating keys: %w", err)
+	}
+
+	return &DB{bolt: db}, nil
+}
+
+// rekey moves entries whose key isn't MatchKey of their artist and track, as written
+// by versions that only folded case, to their current key
+func rekey(bucket *bolt.Bucket) error {
+	moved := make(map[string][]byte)
+	var stale [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return err
+		}
+		artist, _ := entry["artist"].(string)
+		track, _ := entry["track"].(string)
+		key := MatchKey(artist, track)
+		if key == string(k) {
+			return nil
+		}
+
+		entry["key"] = key
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		moved[key] = data
+		stale = append(stale, append([]byte(nil), k...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range stale {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	for key, data := range moved {
+		// An entry already under the new key is newer, keep it
+		if bucket.Get([]byte(key)) != nil {
+			continue
+		}
+		if err := bucket.Put([]byte(key), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DB) Close() error {
+	return d.bolt.Close()
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTestDB opens a database in a temporary directory that is removed after the test
+func openTestDB(t *testing.T) *DB {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpenRekeysOldEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys used to only fold case
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(overridesBucket).Put([]byte("queen\x1fsong (remastered)"), []byte(`{"key": "queen\u001fsong (remastered)", "artist": "Queen", "track": "Song (Remastered)", "uri": "spotify:track:1"}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	override, err := db.Override("Queen", "Song")
+	if err != nil || override == nil || override.Uri != "spotify:track:1" || override.Key != MatchKey("Queen", "Song") {
+		t.Errorf("override = %+v, %v", override, err)
+	}
+	if overrides, _ := db.Overrides(); len(overrides) != 1 {
+		t.Errorf("%d overrides after rekeying, want 1", len(overrides))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/lastfm"
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// Function to download scrobbles newer than the newest one in the store. With an
+// empty store everything since the given time is downloaded, or the full history
+// when since is zero. Pages are stored oldest first as they arrive, so a sync that
+// fails partway keeps what it stored and the next one carries on from there.
+func SyncScrobbles(ctx context.Context, client *lastfm.Client, user string, db *store.DB, since time.Time) (int, error) {
+	if latest, ok, err := db.LatestScrobble(); err != nil {
+		return 0, err
+	} else if ok {
+		since = latest.Add(time.Second)
+	}
+
+	var from int64
+	if !since.IsZero() {
+		from = since.Unix()
+	}
+
+	added := 0
+	err := client.WalkRecentTracks(ctx, user, from, 0, func(tracks []lastfm.Track) error {
+		n, err := db.AddScrobbles(tracks)
+		added += n
+		return err
+	})
+	if err != nil && since.IsZero() {
+		return added, fmt.Errorf("fetching Last.fm history: %w", err)
+	}
+	if err != nil {
+		return added, fmt.Errorf("fetching Last.fm tracks since %s: %w", since.Format(time.RFC3339), err)
+	}
+	return added, nil
+}
+
+// Function to run the sync command, which updates the local scrobble database
+func runSyncCommand(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	sinceFlag := flags.String("since", "", "On the first sync, only download scrobbles since this date (YYYY-MM-DD). Defaults to the full history.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		var err error
+		if since, err = time.ParseInLocation("2006-01-02", *sinceFlag, time.Local); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+
+	lastFmApiKey := os.Getenv("LASTFM_API_KEY")
+	lastFmUser := os.Getenv("LASTFM_USER")
+	if lastFmApiKey == "" || lastFmUser == "" {
+		return fmt.Errorf("%w: LASTFM_API_KEY and LASTFM_USER must be set in .env file", errNotConfigured)
+	}
+
+	db, err := OpenStoreFromEnv()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	client := lastfm.NewClient(lastFmApiKey)
+	client.Logger = log.New(os.Stdout, "", 0)
+
+	added, err := SyncScrobbles(context.Background(), client, lastFmUser, db, since)
+	if err != nil {
+		if added > 0 {
+			fmt.Printf("Added %d new scrobbles before failing, run sync again to resume\n", added)
+		}
+		return err
+	}
+
+	total, err := db.CountScrobbles()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %d new scrobbles, %d stored in total\n", added, total)
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/store"
+)
+
+// Matches below this confidence are listed for review by default
+const DefaultReviewThreshold = 0.85
+
+// Function to build the review report of a run: every track no search found and every
+// match that scored below threshold. Tracks the user already dealt with, by an
+// override or an exclude rule, are never listed, and neither are duplicates.
+func NewRunReport(result *Result, threshold float64) store.RunReport {
+	report := store.RunReport{
+		PlaylistName: result.PlaylistName,
+		PlaylistId:   result.PlaylistId,
+		At:           time.Now(),
+		Matched:      len(result.Matched),
+	}
+	for _, t := range result.Unmatched {
+		if errors.Is(t.err, errSkipped) || errors.Is(t.err, errExcluded) || errors.Is(t.err, errDuplicate) {
+			continue
+		}
+		report.Unmatched = append(report.Unmatched, store.ReviewTrack{
+			Rank:       t.Rank,
+			Artist:     t.Artist,
+			Name:       t.Name,
+			Album:      t.Album,
+			Plays:      t.Plays,
+			Reason:     t.Reason,
+			Candidates: t.Candidates,
+		})
+	}
+	for _, t := range result.Matched {
+		// Matches cached before scoring have no confidence to judge
+		if t.Method == methodOverride || t.Confidence == 0 || t.Confidence >= threshold {
+			continue
+		}
+		report.LowConfidence = append(report.LowConfidence, store.ReviewTrack{
+			Rank:       t.Rank,
+			Artist:     t.Artist,
+			Name:       t.Name,
+			Album:      t.Album,
+			Plays:      t.Plays,
+			Uri:        t.Uri,
+			Confidence: t.Confidence,
+			Method:     t.Method,
+			Candidates: t.Alternatives,
+		})
+	}
+	return report
+}
+
+// Function to pick the reports to show: the latest run of each playlist, or every
+// stored run with all set. An empty playlist name matches every playlist.
+func selectRunReports(db *store.DB, playlist string, all bool) ([]store.RunReport, error) {
+	reports, err := db.RunReports()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	selected := []store.RunReport{}
+	for _, report := range reports {
+		if playlist != "" && report.PlaylistName != playlist {
+			continue
+		}
+		if !all && seen[report.PlaylistName] {
+			continue
+		}
+		seen[report.PlaylistName] = true
+		selected = append(selected, report)
+	}
+	return selected, nil
+}
+
+// Function to run the unmatched command, which lists tracks from recent runs that need an override
+func runUnmatchedCommand(args []string) error {
+	flags := flag.NewFlagSet("unmatched", flag.ContinueOnError)
+	playlist := flags.String("playlist", "", "Only show runs of this playlist")
+	all := flags.Bool("all", false, "Show every stored run instead of the latest of each playlist")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := OpenStoreFromEnv()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	reports, err := selectRunReports(db, *playlist, *all)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("No runs recorded yet")
+		return nil
+	}
+	for _, report := range reports {
+		printRunReport(report, os.Stdout)
+	}
+	fmt.Println("Pin a track with: playlistinator override add <artist> <track> <spotify uri>")
+	return nil
+}
+
+// Function to print one run report with the candidates tried for each track
+func printRunReport(report store.RunReport, w io.Writer) {
+	fmt.Fprintf(w, "%s, run %s: %d matched, %d unmatched, %d to review\n",
+		report.PlaylistName, report.At.Local().Format("2006-01-02 15:04"), report.Matched, len(report.Unmatched), len(report.LowConfidence))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	printReviewTracks := func(title string, tracks []store.ReviewTrack) {
+		if len(tracks) == 0 {
+			return
+		}
+		fmt.Fprintf(tw, "\n%s\n", title)
+		fmt.Fprintln(tw, "RANK\tPLAYS\tARTIST\tTRACK\tRESULT\t")
+		for _, t := range tracks {
+			result := t.Reason
+			if t.Uri != "" {
+				result = fmt.Sprintf("%s (%.2f, %s)", t.Uri, t.Confidence, t.Method)
+			}
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t\n", t.Rank, t.Plays, t.Artist, t.Name, result)
+			for _, c := range t.Candidates {
+				fmt.Fprintf(tw, "\t\t\t  or %s - %s\t%s (%.2f)\t\n", c.Artist, c.Name, c.Uri, c.Score)
+			}
+		}
+	}
+	printReviewTracks("Unmatched", report.Unmatched)
+	printReviewTracks("Low confidence", report.LowConfidence)
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// API response for the unmatched endpoint
+type UnmatchedResponse struct {
+	Success bool              `json:"success"`
+	Runs    []store.RunReport `json:"runs"`
+}
+
+// API handler listing tracks from recent runs that need review. Takes the optional
+// query parameters playlist and all, like the unmatched command.
+func handleUnmatched(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writeError(w, fmt.Errorf("%w: %s", errMethodNotAllowed, r.Method))
+			return
+		}
+		if db == nil {
+			writeError(w, fmt.Errorf("%w: run reports need the local database, see PLAYLISTINATOR_DB", errNotConfigured))
+			return
+		}
+
+		query := r.URL.Query()
+		reports, err := selectRunReports(db, query.Get("playlist"), query.Get("all") == "true" || query.Get("all") == "1")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(UnmatchedResponse{Success: true, Runs: reports})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewRunReport(t *testing.T) {
+	result := &Result{
+		PlaylistName: "Hot 100",
+		Matched: []ResolvedTrack{
+			{Rank: 1, Name: "Sure", Uri: "spotify:track:1", Confidence: 0.95, Method: methodStrict},
+			{Rank: 2, Name: "Doubtful", Uri: "spotify:track:2", Confidence: 0.75, Method: methodFreeText},
+			{Rank: 3, Name: "Pinned", Uri: "spotify:track:3", Confidence: 0.5, Method: methodOverride},
+			{Rank: 4, Name: "Cached before scoring", Uri: "spotify:track:4"},
+		},
+		Unmatched: []UnmatchedTrack{
+			{Rank: 5, Name: "Missing", err: errNoMatch},
+			{Rank: 6, Name: "Skipped", err: errSkipped},
+			{Rank: 7, Name: "Excluded", err: fmt.Errorf("%w: spotify:track:7", errExcluded)},
+			{Rank: 8, Name: "Remaster", err: fmt.Errorf("%w: spotify:track:1", errDuplicate)},
+		},
+	}
+
+	report := NewRunReport(result, DefaultReviewThreshold)
+	if report.Matched != 4 {
+		t.Errorf("matched = %d", report.Matched)
+	}
+	if len(report.Unmatched) != 1 || report.Unmatched[0].Name != "Missing" {
+		t.Errorf("unmatched = %+v, want only the track no search found", report.Unmatched)
+	}
+	if len(report.LowConfidence) != 1 || report.LowConfidence[0].Name != "Doubtful" {
+		t.Errorf("low confidence = %+v", report.LowConfidence)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejaskoundinya/playlistinator/ranking"
+)
+
+const day = 24 * time.Hour
+
+// Units of relative windows. Months are "mo" so that "m" keeps meaning minutes.
+var windowUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"d", day},
+	{"w", 7 * day},
+	{"mo", 30 * day},
+	{"y", 365 * day},
+}
+
+// Function to parse a relative window such as 7d, 2w, 6mo or 1y. Months count as
+// 30 days and years as 365. Plain Go durations like 12h or 30m are accepted too.
+func ParseWindow(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty window")
+	}
+
+	for _, u := range windowUnits {
+		if !strings.HasSuffix(value, u.suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(value, u.suffix))
+		if err == nil {
+			if n <= 0 {
+				return 0, fmt.Errorf("window %q must be positive", value)
+			}
+			return time.Duration(n) * u.unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q, use something like 7d, 90d or 1y", value)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("window %q must be positive", value)
+	}
+	return d, nil
+}
+
+// Function to parse an absolute date, either YYYY-MM-DD in local time or RFC 3339.
+// With endOfDay set a bare date means the end of that day, so -to includes it.
+func ParseDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// Range returns the time range to rank, resolving relative windows against now
+func (o Options) Range(now time.Time) (time.Time, time.Time) {
+	to := now
+	if !o.To.IsZero() {
+		to = o.To
+	}
+	if !o.From.IsZero() {
+		return o.From, to
+	}
+	return to.Add(-o.Window), to
+}
+
+// Function to describe a range for playlist descriptions, e.g. "the last 90 days" or "Jan 2 2024 to Mar 31 2024"
+func (o Options) WindowLabel() string {
+	if !o.From.IsZero() || !o.To.IsZero() {
+		from, to := o.Range(time.Now())
+		// to is exclusive, show the last day that is included
+		return fmt.Sprintf("%s to %s", from.Format("Jan 2 2006"), to.Add(-time.Second).Format("Jan 2 2006"))
+	}
+
+	switch w := o.Window; {
+	case w == 365*day:
+		return "the last year"
+	case w%(365*day) == 0:
+		return fmt.Sprintf("the last %d years", w/(365*day))
+	case w == 7*day:
+		return "the last week"
+	case w == day:
+		return "the last day"
+	case w%day == 0:
+		return fmt.Sprintf("the last %d days", w/day)
+	}
+	return fmt.Sprintf("the last %s", o.Window)
+}
+
+// Validate checks that the options describe a usable range, limits, filters, ranking and description
+func (o Options) Validate() error {
+	if o.Limit <= 0 {
+		return fmt.Errorf("limit must be positive, got %d", o.Limit)
+	}
+	from, to := o.Range(time.Now())
+	if !from.Before(to) {
+		return fmt.Errorf("start of range %s is not before its end %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if o.MaxPerArtist < 0 || o.MaxPerAlbum < 0 || o.MinArtists < 0 || o.MinPlays < 0 {
+		return fmt.Errorf("artist, album and play count limits can't be negative")
+	}
+	if _, err := newTrackFilter(o); err != nil {
+		return err
+	}
+	if _, err := o.Ranker(); err != nil {
+		return err
+	}
+	if _, err := parseDescription(o.Description); err != nil {
+		return fmt.Errorf("invalid description template: %w", err)
+	}
+	return nil
+}
+
+// windowFlags are the -from, -to, -window and -limit flags shared by commands that rank scrobbles
+type windowFlags struct {
+	from   *string
+	to     *string
+	window *string
+	limit  *int
+
+	ranking  *string
+	halfLife *string
+}
+
+func addWindowFlags(flags *flag.FlagSet) *windowFlags {
+	return &windowFlags{
+		from:   flags.String("from", "", "Start of the range to rank (YYYY-MM-DD), overrides -window"),
+		to:     flags.String("to", "", "End of the range to rank, inclusive (YYYY-MM-DD). Defaults to now"),
+		window: flags.String("window", "", "Relative range to rank, e.g. 7d, 90d or 1y. Defaults to 30d"),
+		limit:  flags.Int("limit", 0, "Number of tracks to include. Defaults to 100"),
+
+		ranking:  flags.String("ranking", "", "How to rank tracks: "+strings.Join(ranking.Names(), ", ")+". Defaults to count"),
+		halfLife: flags.String("half-life", "", "Half-life of a play for the decay ranking, e.g. 3d. Defaults to 7d"),
+	}
+}
+
+// apply copies the flags that were set into opts
+func (f *windowFlags) apply(opts *Options) error {
+	if err := applyRanking(opts, *f.ranking, *f.halfLife); err != nil {
+		return err
+	}
+	return applyWindow(opts, *f.from, *f.to, *f.window, *f.limit)
+}
+
+// Function to apply a ranking strategy and half-life given as strings. Empty values are ignored.
+func applyRanking(opts *Options, name string, halfLife string) error {
+	if name != "" {
+		opts.Ranking = name
+	}
+	if halfLife != "" {
+		d, err := ParseWindow(halfLife)
+		if err != nil {
+			return fmt.Errorf("invalid half-life: %w", err)
+		}
+		opts.HalfLife = d
+	}
+	return nil
+}
+
+// Function to apply window settings given as strings, from flags or API requests. Empty values are ignored.
+func applyWindow(opts *Options, from string, to string, window string, limit int) error {
+	if window != "" {
+		w, err := ParseWindow(window)
+		if err != nil {
+			return err
+		}
+		opts.Window = w
+	}
+	if from != "" {
+		t, err := ParseDate(from, false)
+		if err != nil {
+			return err
+		}
+		opts.From = t
+	}
+	if to != "" {
+		t, err := ParseDate(to, true)
+		if err != nil {
+			return err
+		}
+		opts.To = t
+	}
+	if limit != 0 {
+		opts.Limit = limit
+	}
+	return opts.Validate()
+}
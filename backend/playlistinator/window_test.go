@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"7d", 7 * day, false},
+		{" 90d ", 90 * day, false},
+		{"2w", 14 * day, false},
+		{"6mo", 180 * day, false},
+		{"1y", 365 * day, false},
+		{"12h", 12 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"0d", 0, true},
+		{"-7d", 0, true},
+		{"-12h", 0, true},
+		{"d", 0, true},
+		{"7 days", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseWindow(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		endOfDay bool
+		want     time.Time
+		err      bool
+	}{
+		{"2024-03-01", false, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), false},
+		{"2024-03-01", true, time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local), false},
+		{"2024-02-29", true, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), false},
+		{"2024-03-01T12:00:00Z", true, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2023-02-29", false, time.Time{}, true},
+		{"2024-13-01", false, time.Time{}, true},
+		{"01/03/2024", false, time.Time{}, true},
+		{"yesterday", false, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDate(tt.value, tt.endOfDay)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyWindow(t *testing.T) {
+	now := time.Now()
+	date := func(value string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02", value, time.Local)
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		window   string
+		wantFrom time.Time
+		wantTo   time.Time
+		err      bool
+	}{
+		{name: "default window", wantFrom: now.Add(-30 * day), wantTo: now},
+		{name: "window", window: "7d", wantFrom: now.Add(-7 * day), wantTo: now},
+		{name: "window ending at to", to: "2024-03-31", window: "1w", wantFrom: date("2024-04-01").Add(-7 * day), wantTo: date("2024-04-01")},
+		{name: "from overrides window", from: "2024-01-01", to: "2024-03-31", window: "7d", wantFrom: date("2024-01-01"), wantTo: date("2024-04-01")},
+		{name: "from until now", from: "2024-01-01", wantFrom: date("2024-01-01"), wantTo: now},
+		{name: "single day", from: "2024-03-01", to: "2024-03-01", wantFrom: date("2024-03-01"), wantTo: date("2024-03-02")},
+		{name: "from after to", from: "2024-03-02", to: "2024-03-01", err: true},
+		{name: "from in the future", from: now.AddDate(0, 0, 2).Format("2006-01-02"), err: true},
+		{name: "bad from", from: "2024-02-30", err: true},
+		{name: "bad to", to: "March", err: true},
+		{name: "bad window", window: "7x", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			err := applyWindow(&opts, tt.from, tt.to, tt.window, 0)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			from, to := opts.Range(now)
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("range = %s to %s, want %s to %s", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
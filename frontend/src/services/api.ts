@@ -2,10 +2,18 @@
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
 
+export interface ApiError {
+  code: string;
+  step?: string;
+  message: string;
+  retryAfter?: number;
+}
+
 export interface GeneratePlaylistResponse {
   success: boolean;
   message: string;
   count?: number;
+  error?: ApiError;
 }
 
 export const generatePlaylist = async (): Promise<GeneratePlaylistResponse> => {
@@ -17,11 +25,14 @@ export const generatePlaylist = async (): Promise<GeneratePlaylistResponse> => {
       },
     });
 
+    const data = await response.json().catch(() => null);
     if (!response.ok) {
+      if (data && data.error) {
+        return data as GeneratePlaylistResponse;
+      }
       throw new Error(`HTTP error! status: ${response.status}`);
     }
 
-    const data = await response.json();
     return data as GeneratePlaylistResponse;
   } catch (error) {
     console.error('Error generating playlist:', error);